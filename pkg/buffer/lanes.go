@@ -0,0 +1,186 @@
+package buffer
+
+import (
+	"sync/atomic"
+)
+
+// Lanes is a bounded multi-producer single-consumer queue with priority lanes.
+// Lane 0 is the lowest priority. The top lane may use reserved capacity that
+// other lanes cannot, and when the queue is full, items from lower lanes are
+// dropped before items of the pushed priority.
+type Lanes[T any] struct {
+	lanes     []*Ring[T]
+	capacity  int64
+	reserved  int64
+	policy    Policy
+	onDiscard func(T)
+
+	count      atomic.Int64
+	dropped    []atomic.Uint64 // per lane, items rejected or evicted
+	overwrites atomic.Uint64
+	drops      atomic.Uint64
+}
+
+// NewLanes creates a queue with n priority lanes sharing capacity items,
+// of which reserved are kept for the top lane
+func NewLanes[T any](n, capacity, reserved int, policy Policy) *Lanes[T] {
+	if n < 1 {
+		n = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+	if reserved < 0 || reserved >= capacity {
+		reserved = 0
+	}
+	l := &Lanes[T]{
+		lanes:    make([]*Ring[T], n),
+		capacity: int64(capacity),
+		reserved: int64(reserved),
+		policy:   policy,
+		dropped:  make([]atomic.Uint64, n),
+	}
+	for i := range l.lanes {
+		// each lane can hold the full capacity, admission is controlled by count
+		l.lanes[i] = NewRing[T](capacity, DropNewest)
+	}
+	return l
+}
+
+// OnDiscard sets a function called with each item evicted to make room.
+// It must be set before the queue is used.
+func (l *Lanes[T]) OnDiscard(fn func(T)) {
+	l.onDiscard = fn
+}
+
+// Push adds an item with the given lane priority. When the queue is full an
+// item from a lower lane is evicted; if there is none, DropOldest evicts the
+// oldest item of the same lane and DropNewest rejects the new item.
+func (l *Lanes[T]) Push(value T, priority int) bool {
+	priority = l.clamp(priority)
+	limit := l.capacity
+	if priority < len(l.lanes)-1 {
+		limit -= l.reserved
+	}
+
+	for {
+		n := l.count.Load()
+		if n < limit {
+			if l.count.CompareAndSwap(n, n+1) {
+				l.lanes[priority].Push(value)
+				return true
+			}
+			continue
+		}
+
+		victim := priority - 1
+		if l.policy == DropOldest {
+			victim = priority
+		}
+		if old, lane, ok := l.pop(0, victim); ok {
+			// the new item takes the evicted item's place in count
+			l.dropped[lane].Add(1)
+			l.overwrites.Add(1)
+			if l.onDiscard != nil {
+				l.onDiscard(old)
+			}
+			l.lanes[priority].Push(value)
+			return true
+		}
+		if l.count.Load() >= limit {
+			l.dropped[priority].Add(1)
+			l.drops.Add(1)
+			return false
+		}
+	}
+}
+
+// Pop removes the oldest item of the highest non-empty lane
+func (l *Lanes[T]) Pop() (T, bool) {
+	for i := len(l.lanes) - 1; i >= 0; i-- {
+		if value, ok := l.lanes[i].Pop(); ok {
+			l.count.Add(-1)
+			return value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// PopBatch appends up to max items to dst, highest priority first, and returns it
+func (l *Lanes[T]) PopBatch(dst []T, max int) []T {
+	for i := 0; i < max; i++ {
+		value, ok := l.Pop()
+		if !ok {
+			break
+		}
+		dst = append(dst, value)
+	}
+	return dst
+}
+
+// Evict removes the oldest item of the lowest non-empty lane up to maxPriority.
+// The item is counted as dropped for its lane and as an overwrite, and is
+// passed to the OnDiscard function like items evicted by Push.
+func (l *Lanes[T]) Evict(maxPriority int) (T, bool) {
+	if maxPriority < 0 {
+		var zero T
+		return zero, false
+	}
+	value, lane, ok := l.pop(0, l.clamp(maxPriority))
+	if ok {
+		l.count.Add(-1)
+		l.dropped[lane].Add(1)
+		l.overwrites.Add(1)
+		if l.onDiscard != nil {
+			l.onDiscard(value)
+		}
+	}
+	return value, ok
+}
+
+// pop removes the oldest item of the lowest non-empty lane in [from, to]
+func (l *Lanes[T]) pop(from, to int) (T, int, bool) {
+	for i := from; i <= to; i++ {
+		if value, ok := l.lanes[i].Pop(); ok {
+			return value, i, true
+		}
+	}
+	var zero T
+	return zero, -1, false
+}
+
+func (l *Lanes[T]) clamp(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority >= len(l.lanes) {
+		return len(l.lanes) - 1
+	}
+	return priority
+}
+
+// Len returns the number of items currently queued
+func (l *Lanes[T]) Len() int {
+	return int(l.count.Load())
+}
+
+// Cap returns the capacity of the queue
+func (l *Lanes[T]) Cap() int {
+	return int(l.capacity)
+}
+
+// Dropped returns the number of items of a lane rejected or evicted
+func (l *Lanes[T]) Dropped(priority int) uint64 {
+	return l.dropped[l.clamp(priority)].Load()
+}
+
+// Overwrites returns the number of items evicted to make room
+func (l *Lanes[T]) Overwrites() uint64 {
+	return l.overwrites.Load()
+}
+
+// Drops returns the number of items rejected
+func (l *Lanes[T]) Drops() uint64 {
+	return l.drops.Load()
+}
@@ -0,0 +1,129 @@
+package buffer
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanes(t *testing.T) {
+	t.Run("Highest lane first", func(t *testing.T) {
+		lanes := NewLanes[int](3, 10, 0, DropNewest)
+		assert.Equal(t, 10, lanes.Cap())
+		lanes.Push(1, 0)
+		lanes.Push(2, 2)
+		lanes.Push(3, 1)
+		lanes.Push(4, 2)
+
+		assert.Equal(t, 4, lanes.Len())
+		assert.Equal(t, []int{2, 4, 3, 1}, lanes.PopBatch(nil, 10))
+		_, ok := lanes.Pop()
+		assert.False(t, ok, "Pop on empty lanes should fail")
+	})
+
+	t.Run("Lower lanes dropped first", func(t *testing.T) {
+		lanes := NewLanes[int](3, 3, 0, DropNewest)
+		var discarded []int
+		lanes.OnDiscard(func(value int) {
+			discarded = append(discarded, value)
+		})
+		lanes.Push(1, 0)
+		lanes.Push(2, 1)
+		lanes.Push(3, 0)
+
+		assert.True(t, lanes.Push(4, 2), "High item should evict a low one")
+		assert.True(t, lanes.Push(5, 2), "High item should evict a low one")
+		assert.True(t, lanes.Push(6, 2), "High item should evict a normal one")
+		assert.False(t, lanes.Push(7, 2), "High item should not evict high items under DropNewest")
+		assert.False(t, lanes.Push(8, 0), "Low item should be rejected")
+
+		assert.Equal(t, []int{1, 3, 2}, discarded)
+		assert.Equal(t, uint64(3), lanes.Dropped(0))
+		assert.Equal(t, uint64(1), lanes.Dropped(1))
+		assert.Equal(t, uint64(1), lanes.Dropped(2))
+		assert.Equal(t, uint64(3), lanes.Overwrites())
+		assert.Equal(t, uint64(2), lanes.Drops())
+		assert.Equal(t, []int{4, 5, 6}, lanes.PopBatch(nil, 10))
+	})
+
+	t.Run("Drop oldest within a lane", func(t *testing.T) {
+		lanes := NewLanes[int](2, 2, 0, DropOldest)
+		lanes.Push(1, 1)
+		lanes.Push(2, 1)
+		assert.True(t, lanes.Push(3, 1))
+		assert.Equal(t, uint64(1), lanes.Dropped(1))
+		assert.Equal(t, []int{2, 3}, lanes.PopBatch(nil, 10))
+	})
+
+	t.Run("Reserved capacity", func(t *testing.T) {
+		lanes := NewLanes[int](2, 4, 2, DropNewest)
+		assert.True(t, lanes.Push(1, 0))
+		assert.True(t, lanes.Push(2, 0))
+		assert.False(t, lanes.Push(3, 0), "Reserved slots should be kept for the top lane")
+		assert.True(t, lanes.Push(4, 1))
+		assert.True(t, lanes.Push(5, 1))
+		assert.Equal(t, 4, lanes.Len())
+		assert.Equal(t, uint64(1), lanes.Dropped(0), "Only the rejected item should be dropped")
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+		lanes := NewLanes[int](3, 10, 0, DropNewest)
+		var discarded []int
+		lanes.OnDiscard(func(value int) {
+			discarded = append(discarded, value)
+		})
+		lanes.Push(1, 2)
+		lanes.Push(2, 1)
+
+		_, ok := lanes.Evict(0)
+		assert.False(t, ok, "Nothing to evict in lane 0")
+		value, ok := lanes.Evict(2)
+		assert.True(t, ok)
+		assert.Equal(t, 2, value, "Lowest lane should be evicted first")
+		assert.Equal(t, 1, lanes.Len())
+		assert.Equal(t, uint64(1), lanes.Dropped(1))
+		assert.Equal(t, uint64(1), lanes.Overwrites())
+		assert.Equal(t, []int{2}, discarded, "Evicted items should be passed to OnDiscard")
+	})
+
+	t.Run("Concurrent producers", func(t *testing.T) {
+		const producers, perProducer = 6, 1000
+		lanes := NewLanes[int](3, 64, 8, DropNewest)
+
+		var wg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perProducer; i++ {
+					lanes.Push(i, p%3)
+				}
+			}(p)
+		}
+
+		popped := 0
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		for running := true; running; {
+			select {
+			case <-done:
+				running = false
+			default:
+			}
+			popped += len(lanes.PopBatch(nil, 16))
+			assert.LessOrEqual(t, lanes.Len(), lanes.Cap())
+		}
+		popped += len(lanes.PopBatch(nil, lanes.Cap()))
+
+		var dropped uint64
+		for p := 0; p < 3; p++ {
+			dropped += lanes.Dropped(p)
+		}
+		assert.Equal(t, producers*perProducer, popped+int(dropped), "Every item should be popped or dropped")
+		assert.Equal(t, 0, lanes.Len())
+	})
+}
@@ -0,0 +1,173 @@
+package buffer
+
+import (
+	"sync/atomic"
+)
+
+// Policy controls what happens when the ring is full
+type Policy int
+
+const (
+	// DropNewest rejects new items while the ring is full
+	DropNewest Policy = iota
+	// DropOldest discards the oldest item to make room for the new one
+	DropOldest
+)
+
+// slot holds one item and a sequence number telling producers and
+// the consumer whose turn it is
+type slot[T any] struct {
+	seq   atomic.Uint64
+	value T
+}
+
+// Ring is a bounded lock-free queue for many producers and a single consumer.
+// Capacity is rounded up to a power of two.
+type Ring[T any] struct {
+	slots     []slot[T]
+	mask      uint64
+	policy    Policy
+	onDiscard func(T)
+
+	_    [8]uint64 // keep head and tail on separate cache lines
+	head atomic.Uint64
+	_    [8]uint64
+	tail atomic.Uint64
+	_    [8]uint64
+
+	overwrites atomic.Uint64
+	drops      atomic.Uint64
+}
+
+// minRingSize is the smallest ring, a single slot cannot tell full from empty
+const minRingSize = 2
+
+// NewRing creates a new ring buffer. The capacity is rounded up to a power
+// of two and is at least minRingSize.
+func NewRing[T any](capacity int, policy Policy) *Ring[T] {
+	capacity = max(capacity, minRingSize)
+	size := uint64(minRingSize)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	r := &Ring[T]{
+		slots:  make([]slot[T], size),
+		mask:   size - 1,
+		policy: policy,
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// OnDiscard sets a function called with each item discarded under DropOldest.
+// It must be set before the ring is used.
+func (r *Ring[T]) OnDiscard(fn func(T)) {
+	r.onDiscard = fn
+}
+
+// Push adds an item. It returns false if the item was dropped because the
+// ring is full and the policy is DropNewest. With DropOldest the oldest
+// item is discarded instead and Push always succeeds.
+func (r *Ring[T]) Push(value T) bool {
+	for {
+		if r.tryPush(value) {
+			return true
+		}
+		if r.policy != DropOldest {
+			r.drops.Add(1)
+			return false
+		}
+		if old, ok := r.Pop(); ok {
+			r.overwrites.Add(1)
+			if r.onDiscard != nil {
+				r.onDiscard(old)
+			}
+		}
+	}
+}
+
+func (r *Ring[T]) tryPush(value T) bool {
+	pos := r.tail.Load()
+	for {
+		s := &r.slots[pos&r.mask]
+		seq := s.seq.Load()
+		switch diff := int64(seq - pos); {
+		case diff == 0:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				s.value = value
+				s.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			return false
+		default:
+			pos = r.tail.Load()
+		}
+	}
+}
+
+// Pop removes the oldest item. It reports false when the ring is empty.
+// Pop is meant for the single consumer, producers only call it to
+// make room under DropOldest.
+func (r *Ring[T]) Pop() (T, bool) {
+	var zero T
+	pos := r.head.Load()
+	for {
+		s := &r.slots[pos&r.mask]
+		seq := s.seq.Load()
+		switch diff := int64(seq - (pos + 1)); {
+		case diff == 0:
+			if r.head.CompareAndSwap(pos, pos+1) {
+				value := s.value
+				s.value = zero
+				s.seq.Store(pos + r.mask + 1)
+				return value, true
+			}
+			pos = r.head.Load()
+		case diff < 0:
+			return zero, false
+		default:
+			pos = r.head.Load()
+		}
+	}
+}
+
+// PopBatch appends up to max items to dst and returns it
+func (r *Ring[T]) PopBatch(dst []T, max int) []T {
+	for i := 0; i < max; i++ {
+		value, ok := r.Pop()
+		if !ok {
+			break
+		}
+		dst = append(dst, value)
+	}
+	return dst
+}
+
+// Len returns the number of items currently queued
+func (r *Ring[T]) Len() int {
+	head := r.head.Load()
+	tail := r.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	return int(tail - head)
+}
+
+// Cap returns the capacity of the ring
+func (r *Ring[T]) Cap() int {
+	return len(r.slots)
+}
+
+// Overwrites returns the number of items discarded by DropOldest
+func (r *Ring[T]) Overwrites() uint64 {
+	return r.overwrites.Load()
+}
+
+// Drops returns the number of items rejected by DropNewest
+func (r *Ring[T]) Drops() uint64 {
+	return r.drops.Load()
+}
@@ -0,0 +1,120 @@
+package buffer
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	t.Run("Basic operations", func(t *testing.T) {
+		ring := NewRing[int](3, DropNewest)
+		assert.Equal(t, 4, ring.Cap(), "Capacity should round up to a power of two")
+		assert.Equal(t, 0, ring.Len())
+
+		_, ok := ring.Pop()
+		assert.False(t, ok, "Pop on empty ring should fail")
+
+		for i := 1; i <= 4; i++ {
+			assert.True(t, ring.Push(i))
+		}
+		assert.False(t, ring.Push(5), "Push on full ring should fail")
+		assert.Equal(t, uint64(1), ring.Drops())
+		assert.Equal(t, 4, ring.Len())
+
+		value, ok := ring.Pop()
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, []int{2, 3, 4}, ring.PopBatch(nil, 10))
+		assert.Equal(t, 0, ring.Len())
+	})
+
+	t.Run("Small capacity", func(t *testing.T) {
+		for _, capacity := range []int{-1, 0, 1} {
+			ring := NewRing[int](capacity, DropNewest)
+			assert.Equal(t, minRingSize, ring.Cap(), "Capacity should be clamped")
+			assert.True(t, ring.Push(1))
+			assert.True(t, ring.Push(2))
+			assert.False(t, ring.Push(3), "Full ring should not overwrite")
+			assert.Equal(t, []int{1, 2}, ring.PopBatch(nil, 10))
+		}
+	})
+
+	t.Run("Drop oldest", func(t *testing.T) {
+		ring := NewRing[int](4, DropOldest)
+		var discarded []int
+		ring.OnDiscard(func(value int) {
+			discarded = append(discarded, value)
+		})
+		for i := 1; i <= 6; i++ {
+			assert.True(t, ring.Push(i))
+		}
+
+		assert.Equal(t, uint64(2), ring.Overwrites())
+		assert.Equal(t, uint64(0), ring.Drops())
+		assert.Equal(t, []int{1, 2}, discarded)
+		assert.Equal(t, []int{3, 4, 5, 6}, ring.PopBatch(nil, 10), "Oldest items should be discarded")
+	})
+
+	t.Run("Concurrent producers", func(t *testing.T) {
+		const producers, perProducer = 8, 1000
+		ring := NewRing[int](64, DropNewest)
+
+		var wg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perProducer; i++ {
+					for !ring.Push(p*perProducer + i) {
+						runtime.Gosched()
+					}
+				}
+			}(p)
+		}
+
+		done := make(chan struct{})
+		seen := make(map[int]bool)
+		go func() {
+			defer close(done)
+			for len(seen) < producers*perProducer {
+				batch := ring.PopBatch(nil, 16)
+				if len(batch) == 0 {
+					runtime.Gosched()
+				}
+				for _, value := range batch {
+					assert.False(t, seen[value], "Item should be received once")
+					seen[value] = true
+				}
+			}
+		}()
+
+		wg.Wait()
+		<-done
+		assert.Len(t, seen, producers*perProducer, "All items should be received")
+	})
+}
+
+func BenchmarkRingPush(b *testing.B) {
+	ring := NewRing[int](1024, DropOldest)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ring.Push(1)
+		}
+	})
+}
+
+func BenchmarkChannelSend(b *testing.B) {
+	ch := make(chan int, 1024)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			select {
+			case ch <- 1:
+			default:
+				<-ch
+			}
+		}
+	})
+}
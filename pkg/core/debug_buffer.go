@@ -0,0 +1,56 @@
+package core
+
+const defaultDebugBufferSize = 100
+
+// DebugBufferConfig represents configuration for holding debug events per trace.
+// Debug events are kept in a bounded buffer and only attached to the trace
+// when it ends and Flush returns true.
+type DebugBufferConfig struct {
+	Size  int               // maximum debug events held per trace, oldest are dropped
+	Flush func(*Trace) bool // decides whether held events are kept, defaults to error state
+}
+
+// flushOnError keeps debug events of failed traces
+func flushOnError(t *Trace) bool {
+	return t.State == "error"
+}
+
+// debugBuffer is a bounded ring of debug events
+type debugBuffer struct {
+	events []*Event
+	next   int
+	full   bool
+	flush  func(*Trace) bool
+}
+
+func newDebugBuffer(config DebugBufferConfig) *debugBuffer {
+	if config.Size <= 0 {
+		config.Size = defaultDebugBufferSize
+	}
+	if config.Flush == nil {
+		config.Flush = flushOnError
+	}
+	return &debugBuffer{
+		events: make([]*Event, config.Size),
+		flush:  config.Flush,
+	}
+}
+
+// push adds an event, overwriting the oldest when full
+func (b *debugBuffer) push(event *Event) {
+	b.events[b.next] = event
+	b.next = (b.next + 1) % len(b.events)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// drain returns held events oldest first
+func (b *debugBuffer) drain() []*Event {
+	if !b.full {
+		return b.events[:b.next]
+	}
+	result := make([]*Event, 0, len(b.events))
+	result = append(result, b.events[b.next:]...)
+	return append(result, b.events[:b.next]...)
+}
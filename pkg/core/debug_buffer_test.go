@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugBuffer(t *testing.T) {
+	newTrace := func(config DebugBufferConfig) *Trace {
+		obs := NewObserver(&Config{BufferSize: 1, DebugBuffer: &config})
+		return obs.StartTrace(SamplingParameters{TraceID: "trace-1"})
+	}
+	now := time.Now()
+
+	t.Run("Discarded on success", func(t *testing.T) {
+		trace := newTrace(DebugBufferConfig{})
+		trace.AddEvent(&Event{Level: "debug", Message: "query", Time: now})
+		trace.AddEvent(&Event{Level: "info", Message: "done", Time: now.Add(time.Millisecond)})
+		trace.End("success")
+
+		assert.Len(t, trace.Events, 1, "Debug events should be discarded")
+		assert.Equal(t, "done", trace.Events[0].Message)
+	})
+
+	t.Run("Flushed on error", func(t *testing.T) {
+		trace := newTrace(DebugBufferConfig{Size: 2})
+		for i := 0; i < 3; i++ {
+			trace.AddEvent(&Event{Level: "debug", Message: fmt.Sprintf("debug %d", i), Time: now.Add(time.Duration(i) * time.Millisecond)})
+		}
+		trace.AddEvent(&Event{Level: "error", Message: "failed", Time: now.Add(-time.Millisecond)})
+		trace.End("error")
+
+		messages := make([]string, 0, len(trace.Events))
+		for _, event := range trace.Events {
+			messages = append(messages, event.Message)
+		}
+		assert.Equal(t, []string{"failed", "debug 1", "debug 2"}, messages, "Should keep newest debug events in time order")
+	})
+
+	t.Run("Custom predicate", func(t *testing.T) {
+		trace := newTrace(DebugBufferConfig{Flush: func(t *Trace) bool { return t.Method == "POST" }})
+		trace.Method = "POST"
+		trace.AddEvent(&Event{Level: "debug", Message: "payload", Time: now})
+		trace.End("success")
+
+		assert.Len(t, trace.Events, 1)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		trace := NewTrace()
+		trace.AddEvent(&Event{Level: "debug", Message: "query", Time: now})
+		trace.End("success")
+
+		assert.Len(t, trace.Events, 1, "Debug events should be recorded directly without buffering")
+	})
+}
@@ -2,20 +2,59 @@ package core
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/buffer"
 )
 
 type observerKey struct{}
 
+// MetricBufferMemory is the SelfMetrics gauge holding the estimated bytes of buffered entries
+const MetricBufferMemory = "buffer_memory_bytes"
+
 // Config represents observer configuration
 type Config struct {
 	Development bool
-	BufferSize  int
+	BufferSize  int                // entries the buffer holds, exact although ring slots are allocated in powers of two
+	BufferBytes int64              // cap on the estimated memory of buffered entries, 0 disables
+	Reserved    int                // buffer slots only high priority entries may use
+	Overflow    buffer.Policy      // what to drop when the buffer is full, defaults to newest
+	Sampler     Sampler            // head sampler consulted in StartTrace, nil samples everything
+	TailSampler *TailSampler       // decides in Enqueue whether a completed trace is kept, nil keeps everything
+	RateLimit   *RateLimitConfig   // per-message rate limit for log events, nil disables
+	DebugBuffer *DebugBufferConfig // hold debug events until the trace fails, nil disables
+
+	// ReleaseEntries returns entries to the pool once Flush has written them
+	// to every writer. Only set it when no writer keeps entries past Write.
+	ReleaseEntries bool
+}
+
+// EntryWriter is implemented by outputs that Flush writes entries to
+type EntryWriter interface {
+	Write(entries []*Entry) error
 }
 
 // Observer handles logging and tracing
 type Observer struct {
-	buffer chan *Entry
-	config *Config
+	buffer  *buffer.Lanes[bufferedEntry]
+	config  *Config
+	limiter *RateLimiter
+	metrics *SelfMetrics
+
+	memory        *atomic.Int64 // estimated bytes of buffered entries, the MetricBufferMemory gauge
+	memoryDropped atomic.Uint64 // entries dropped by the memory budget
+
+	flushMu sync.Mutex
+	batch   []*Entry // reused by Flush
+}
+
+// bufferedEntry is an entry with the size it was accounted with on enqueue
+type bufferedEntry struct {
+	entry *Entry
+	size  int64
 }
 
 // NewObserver creates a new observer
@@ -26,15 +65,202 @@ func NewObserver(config *Config) *Observer {
 			BufferSize:  1000,
 		}
 	}
-	return &Observer{
-		buffer: make(chan *Entry, config.BufferSize),
-		config: config,
+	obs := &Observer{
+		buffer:  buffer.NewLanes[bufferedEntry](priorityCount, config.BufferSize, config.Reserved, config.Overflow),
+		config:  config,
+		metrics: NewSelfMetrics(),
+	}
+	obs.memory = obs.metrics.value(MetricBufferMemory)
+	obs.buffer.OnDiscard(func(item bufferedEntry) {
+		obs.memory.Add(-item.size)
+	})
+	if config.RateLimit != nil {
+		obs.limiter = NewRateLimiter(*config.RateLimit)
+	}
+	return obs
+}
+
+// SelfMetrics returns the observer's own metrics, shared with outputs
+// and other components that report on their health
+func (o *Observer) SelfMetrics() *SelfMetrics {
+	return o.metrics
+}
+
+// BufferStats represents entry buffer statistics
+type BufferStats struct {
+	Depth         int
+	Capacity      int
+	Overwrites    uint64 // entries discarded to make room for new ones
+	Dropped       uint64 // new entries rejected because the buffer was full
+	MemoryBytes   int64  // estimated memory of buffered entries
+	MemoryLimit   int64  // Config.BufferBytes, 0 when unlimited
+	MemoryDropped uint64 // entries dropped by the memory budget
+
+	DroppedByPriority [priorityCount]uint64 // entries lost per Priority, rejected or discarded
+}
+
+// Enqueue adds an entry to the buffer, it reports false if the entry was dropped.
+// Completed entries are first passed to the tail sampler, each decision is
+// stored in Entry.Sampling and counted in SelfMetrics as sampler.tail.<reason>. Both BufferSize and
+// BufferBytes apply. Under pressure entries of lower priority are dropped
+// first; among entries of the same priority the overflow policy decides
+// whether the new or the oldest entry is dropped.
+func (o *Observer) Enqueue(entry *Entry) bool {
+	if !o.tailSample(entry) {
+		return false
+	}
+	priority := EntryPriority(entry)
+	item := bufferedEntry{entry: entry, size: int64(EstimateSize(entry))}
+	if !o.reserveMemory(item.size, priority) {
+		o.memoryDropped.Add(1)
+		return false
+	}
+	if !o.buffer.Push(item, int(priority)) {
+		o.memory.Add(-item.size)
+		return false
+	}
+	return true
+}
+
+// reserveMemory adds size to the memory in use, evicting entries up to
+// the victim priority while the budget would be exceeded. The bytes are
+// reserved before checking so concurrent producers cannot overshoot.
+func (o *Observer) reserveMemory(size int64, priority Priority) bool {
+	limit := o.config.BufferBytes
+	if limit <= 0 {
+		o.memory.Add(size)
+		return true
+	}
+	if size > limit {
+		return false
+	}
+	victim := priority - 1
+	if o.config.Overflow == buffer.DropOldest {
+		victim = priority
+	}
+	for o.memory.Add(size) > limit {
+		o.memory.Add(-size)
+		// evicted entries are released from the budget by OnDiscard
+		if _, ok := o.buffer.Evict(int(victim)); !ok {
+			return false
+		}
+		o.memoryDropped.Add(1)
+	}
+	return true
+}
+
+// tailSample reports whether a completed entry is kept by the tail sampler
+// and records the decision in entry.Sampling for outputs to emit
+func (o *Observer) tailSample(entry *Entry) bool {
+	if o.config.TailSampler == nil || (entry.State != "success" && entry.State != "error") {
+		return true
+	}
+	decision := o.config.TailSampler.Decide(TailSamplingParameters{
+		TraceID:  entry.TraceID,
+		State:    entry.State,
+		Duration: time.Duration(entry.Duration * float64(time.Second)),
+	})
+	o.metrics.Add("sampler.tail."+decision.Reason, 1)
+	entry.Sampling = &decision
+	return decision.Sampled
+}
+
+// Dequeue appends up to max buffered entries to dst and returns it
+func (o *Observer) Dequeue(dst []*Entry, max int) []*Entry {
+	var size int64
+	for i := 0; i < max; i++ {
+		item, ok := o.buffer.Pop()
+		if !ok {
+			break
+		}
+		size += item.size
+		dst = append(dst, item.entry)
+	}
+	o.memory.Add(-size)
+	return dst
+}
+
+// Flush takes up to max entries off the buffer and writes them to every
+// writer in turn. With Config.ReleaseEntries the entries are released to the
+// pool after the last writer has returned. It returns the number of entries
+// flushed and the errors of all writers.
+func (o *Observer) Flush(max int, writers ...EntryWriter) (int, error) {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+
+	o.batch = o.Dequeue(o.batch[:0], max)
+	if len(o.batch) == 0 {
+		return 0, nil
+	}
+	var errs []error
+	for _, w := range writers {
+		if err := w.Write(o.batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	n := len(o.batch)
+	if o.config.ReleaseEntries {
+		ReleaseEntries(o.batch)
 	}
+	clear(o.batch)
+	return n, errors.Join(errs...)
 }
 
-// Buffer returns the entry buffer channel
-func (o *Observer) Buffer() chan *Entry {
-	return o.buffer
+// MemoryUsage returns the estimated memory of buffered entries in bytes
+func (o *Observer) MemoryUsage() int64 {
+	return o.memory.Load()
+}
+
+// BufferStats returns entry buffer statistics
+func (o *Observer) BufferStats() BufferStats {
+	stats := BufferStats{
+		Depth:         o.buffer.Len(),
+		Capacity:      o.buffer.Cap(),
+		Overwrites:    o.buffer.Overwrites(),
+		Dropped:       o.buffer.Drops(),
+		MemoryBytes:   o.memory.Load(),
+		MemoryLimit:   o.config.BufferBytes,
+		MemoryDropped: o.memoryDropped.Load(),
+	}
+	for p := range stats.DroppedByPriority {
+		stats.DroppedByPriority[p] = o.buffer.Dropped(p)
+	}
+	return stats
+}
+
+// AllowEvent reports whether a log event should be emitted under the rate limit.
+// A non-nil suppression means earlier events were dropped and a summary should be emitted.
+func (o *Observer) AllowEvent(level, message string) (bool, *Suppression) {
+	if o.limiter == nil {
+		return true, nil
+	}
+	return o.limiter.Allow(level, message)
+}
+
+// RateLimiter returns the event rate limiter, nil when disabled
+func (o *Observer) RateLimiter() *RateLimiter {
+	return o.limiter
+}
+
+// StartTrace starts a trace for a request and consults the sampler.
+// Unsampled traces are still returned so timing and state can feed metrics,
+// but they record no spans. The trace comes from the pool and may be
+// returned with ReleaseTrace once it has been converted to an entry.
+func (o *Observer) StartTrace(params SamplingParameters) *Trace {
+	trace := AcquireTrace()
+	trace.TraceID = params.TraceID
+	trace.Method = params.Method
+	trace.OriginalPath = params.Path
+	trace.StartTime = time.Now()
+	trace.State = "processing"
+	if o.config.Sampler != nil {
+		trace.dropped = !o.config.Sampler.ShouldSample(params)
+	}
+	trace.limiter = o.limiter
+	if !trace.dropped && o.config.DebugBuffer != nil {
+		trace.debug = newDebugBuffer(*o.config.DebugBuffer)
+	}
+	return trace
 }
 
 // WithObserver adds observer to context
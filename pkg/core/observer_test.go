@@ -0,0 +1,161 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/buffer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserverBuffer(t *testing.T) {
+	newEntry := func(payload int) *Entry {
+		return &Entry{TraceID: "trace", OriginalPath: strings.Repeat("x", payload)}
+	}
+	entrySize := int64(EstimateSize(newEntry(1000)))
+
+	t.Run("Memory accounting", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 10})
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.Equal(t, 2*entrySize, obs.MemoryUsage())
+
+		entries := obs.Dequeue(nil, 1)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, entrySize, obs.MemoryUsage())
+		obs.Dequeue(nil, 10)
+		assert.Zero(t, obs.MemoryUsage())
+	})
+
+	t.Run("Negative size", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: -1})
+		assert.True(t, obs.Enqueue(newEntry(10)))
+		assert.Equal(t, 1, obs.BufferStats().Capacity)
+	})
+
+	t.Run("Budget drops newest", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 10, BufferBytes: 2*entrySize + 10})
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.False(t, obs.Enqueue(newEntry(1000)), "Entry over budget should be dropped")
+		assert.False(t, obs.Enqueue(newEntry(10000)), "Entry larger than the budget should be dropped")
+
+		stats := obs.BufferStats()
+		assert.Equal(t, 2, stats.Depth)
+		assert.Equal(t, 2*entrySize, stats.MemoryBytes)
+		assert.Equal(t, 2*entrySize+10, stats.MemoryLimit)
+		assert.Equal(t, uint64(2), stats.MemoryDropped)
+	})
+
+	t.Run("Concurrent budget", func(t *testing.T) {
+		limit := 10*entrySize + entrySize/2
+		obs := NewObserver(&Config{BufferSize: 1000, BufferBytes: limit})
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					obs.Enqueue(newEntry(1000))
+				}
+			}()
+		}
+		wg.Wait()
+
+		stats := obs.BufferStats()
+		assert.Equal(t, 10, stats.Depth, "Budget should hold exactly as many entries as fit")
+		assert.Equal(t, 10*entrySize, stats.MemoryBytes)
+		assert.Equal(t, 10*entrySize, obs.SelfMetrics().Get(MetricBufferMemory), "Gauge should track memory use")
+		assert.Len(t, obs.Dequeue(nil, 100), 10)
+		assert.Zero(t, obs.SelfMetrics().Get(MetricBufferMemory))
+	})
+
+	t.Run("Oversized entry", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 10, BufferBytes: 2 * entrySize})
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.True(t, obs.Enqueue(newEntry(1000)))
+		assert.False(t, obs.Enqueue(&Entry{State: "error", OriginalPath: strings.Repeat("x", 10000)}),
+			"Entry larger than the budget should be dropped")
+		assert.Equal(t, 2, obs.BufferStats().Depth, "Nothing should be evicted for an entry that cannot fit")
+	})
+
+	t.Run("Budget evicts lower priority", func(t *testing.T) {
+		newStateEntry := func(state string) *Entry {
+			return &Entry{State: state, OriginalPath: strings.Repeat("x", 1000)}
+		}
+		obs := NewObserver(&Config{BufferSize: 10, BufferBytes: int64(2 * EstimateSize(newStateEntry("success")))})
+		assert.True(t, obs.Enqueue(newStateEntry("success")))
+		assert.True(t, obs.Enqueue(newStateEntry("success")))
+		assert.True(t, obs.Enqueue(newStateEntry("error")))
+
+		stats := obs.BufferStats()
+		assert.Equal(t, 2, stats.Depth)
+		assert.Equal(t, uint64(1), stats.DroppedByPriority[PriorityLow], "Evictions should count as priority drops")
+		assert.Equal(t, uint64(1), stats.Overwrites)
+		obs.Dequeue(nil, 10)
+		assert.Zero(t, obs.MemoryUsage(), "Evicted entries should be released from the budget")
+	})
+
+	t.Run("Budget drops oldest", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 10, BufferBytes: 2 * entrySize, Overflow: buffer.DropOldest})
+		first := newEntry(1000)
+		obs.Enqueue(first)
+		obs.Enqueue(newEntry(1000))
+		assert.True(t, obs.Enqueue(newEntry(1000)), "Oldest entry should make room")
+
+		entries := obs.Dequeue(nil, 10)
+		assert.Len(t, entries, 2)
+		for _, entry := range entries {
+			assert.NotSame(t, first, entry, "Oldest entry should have been dropped")
+		}
+		assert.Zero(t, obs.MemoryUsage())
+	})
+
+	t.Run("Exact capacity", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 3})
+		for i := 0; i < 5; i++ {
+			obs.Enqueue(newEntry(10))
+		}
+		stats := obs.BufferStats()
+		assert.Equal(t, 3, stats.Capacity, "Capacity should not be rounded to a power of two")
+		assert.Equal(t, 3, stats.Depth)
+		assert.Equal(t, uint64(2), stats.Dropped)
+	})
+
+	t.Run("Ring overwrites", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 2, Overflow: buffer.DropOldest})
+		for i := 0; i < 5; i++ {
+			obs.Enqueue(newEntry(1000))
+		}
+		assert.Equal(t, 2*entrySize, obs.MemoryUsage(), "Overwritten entries should be released from the budget")
+		assert.Equal(t, uint64(3), obs.BufferStats().Overwrites)
+	})
+}
+
+func TestEntryPriority(t *testing.T) {
+	assert.Equal(t, PriorityLow, EntryPriority(&Entry{State: "success"}))
+	assert.Equal(t, PriorityNormal, EntryPriority(&Entry{State: "processing"}))
+	assert.Equal(t, PriorityHigh, EntryPriority(&Entry{State: "error"}))
+	assert.Equal(t, "high", PriorityHigh.String())
+}
+
+func TestObserverPriority(t *testing.T) {
+	obs := NewObserver(&Config{BufferSize: 3, Reserved: 1})
+	for i := 0; i < 3; i++ {
+		obs.Enqueue(&Entry{State: "success"})
+	}
+	assert.Equal(t, 2, obs.BufferStats().Depth, "Reserved slot should be kept for errors")
+
+	failed := &Entry{State: "error"}
+	assert.True(t, obs.Enqueue(failed))
+	assert.True(t, obs.Enqueue(&Entry{State: "error"}), "Error entry should evict a successful one")
+
+	stats := obs.BufferStats()
+	assert.Equal(t, 3, stats.Depth)
+	assert.Equal(t, uint64(2), stats.DroppedByPriority[PriorityLow])
+	assert.Zero(t, stats.DroppedByPriority[PriorityHigh])
+
+	entries := obs.Dequeue(nil, 10)
+	assert.Same(t, failed, entries[0], "High priority entries should be dequeued first")
+}
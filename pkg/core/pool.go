@@ -0,0 +1,83 @@
+package core
+
+import (
+	"sync"
+)
+
+// Pooled objects follow a single ownership rule: whoever acquires an object
+// releases it exactly once, after the last reader is done with it. For entries
+// taken from the observer buffer that is Observer.Flush, after every output's
+// Write has returned, when Config.ReleaseEntries is set. Outputs must not keep
+// entries past Write; outputs that retain entries (such as TestOutput or a
+// QueueOutput) must be used without releasing.
+
+var (
+	entryPool = sync.Pool{
+		New: func() interface{} { return new(Entry) },
+	}
+	tracePool = sync.Pool{
+		New: func() interface{} { return new(Trace) },
+	}
+	spanPool = sync.Pool{
+		New: func() interface{} { return new(Span) },
+	}
+)
+
+// AcquireEntry returns an empty entry from the pool
+func AcquireEntry() *Entry {
+	return entryPool.Get().(*Entry)
+}
+
+// ReleaseEntry resets an entry and returns it to the pool
+func ReleaseEntry(e *Entry) {
+	if e == nil {
+		return
+	}
+	spans := e.Spans
+	clear(spans)
+	*e = Entry{}
+	e.Spans = spans[:0]
+	entryPool.Put(e)
+}
+
+// ReleaseEntries releases every entry in a flushed batch
+func ReleaseEntries(entries []*Entry) {
+	for _, e := range entries {
+		ReleaseEntry(e)
+	}
+}
+
+// AcquireTrace returns an empty, sampled trace from the pool
+func AcquireTrace() *Trace {
+	return tracePool.Get().(*Trace)
+}
+
+// ReleaseTrace resets a trace, releases its spans and returns it to the pool
+func ReleaseTrace(t *Trace) {
+	if t == nil {
+		return
+	}
+	for _, span := range t.Spans {
+		ReleaseSpan(span)
+	}
+	spans, events := t.Spans, t.Events
+	clear(spans)
+	clear(events)
+	*t = Trace{}
+	t.Spans, t.Events = spans[:0], events[:0]
+	tracePool.Put(t)
+}
+
+// AcquireSpan returns an empty span from the pool
+func AcquireSpan() *Span {
+	return spanPool.Get().(*Span)
+}
+
+// ReleaseSpan resets a span and returns it to the pool
+func ReleaseSpan(s *Span) {
+	if s == nil {
+		return
+	}
+	*s = Span{}
+	spanPool.Put(s)
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingWriter keeps the trace IDs of the entries it was given
+type recordingWriter struct {
+	traceIDs []string
+	err      error
+}
+
+func (w *recordingWriter) Write(entries []*Entry) error {
+	for _, entry := range entries {
+		w.traceIDs = append(w.traceIDs, entry.TraceID)
+	}
+	return w.err
+}
+
+func TestPool(t *testing.T) {
+	trace := AcquireTrace()
+	trace.TraceID = "trace-1"
+	trace.State = "error"
+	trace.AddSpan(AcquireSpan())
+	trace.AddEvent(&Event{Level: "info", Message: "done"})
+	ReleaseTrace(trace)
+
+	assert.Empty(t, trace.TraceID, "Released trace should be reset")
+	assert.Empty(t, trace.State)
+	assert.Empty(t, trace.Spans)
+	assert.Empty(t, trace.Events)
+
+	trace = AcquireTrace()
+	assert.True(t, trace.Sampled(), "Acquired trace should be sampled")
+
+	entry := AcquireEntry()
+	entry.TraceID = "trace-1"
+	ReleaseEntries([]*Entry{entry, nil})
+	assert.Empty(t, entry.TraceID, "Released entry should be reset")
+}
+
+func TestObserverFlush(t *testing.T) {
+	t.Run("Writes to every writer", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 4})
+		kept := &Entry{TraceID: "trace-1"}
+		obs.Enqueue(kept)
+		obs.Enqueue(&Entry{TraceID: "trace-2"})
+
+		first, second := &recordingWriter{}, &recordingWriter{}
+		n, err := obs.Flush(10, first, second)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"trace-1", "trace-2"}, first.traceIDs)
+		assert.Equal(t, first.traceIDs, second.traceIDs)
+		assert.Equal(t, "trace-1", kept.TraceID, "Entries should not be released by default")
+		assert.Zero(t, obs.BufferStats().Depth)
+	})
+
+	t.Run("Releases after the last writer", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 4, ReleaseEntries: true})
+		entry := AcquireEntry()
+		entry.TraceID = "trace-1"
+		obs.Enqueue(entry)
+
+		failing, last := &recordingWriter{err: errors.New("sink unavailable")}, &recordingWriter{}
+		n, err := obs.Flush(10, failing, last)
+		assert.EqualError(t, err, "sink unavailable")
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"trace-1"}, last.traceIDs, "Later writers should run after a failure")
+		assert.Empty(t, entry.TraceID, "Flushed entry should be released")
+	})
+
+	t.Run("Empty buffer", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 4})
+		writer := &recordingWriter{}
+		n, err := obs.Flush(10, writer)
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Empty(t, writer.traceIDs)
+	})
+}
+
+// discardWriter drops entries like an output that has encoded them
+type discardWriter struct{}
+
+func (discardWriter) Write(entries []*Entry) error {
+	return nil
+}
+
+// BenchmarkRequest runs the request hot path: a trace with spans is turned
+// into an entry, buffered and flushed to an output
+func BenchmarkRequest(b *testing.B) {
+	obs := NewObserver(&Config{BufferSize: 64})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		trace := NewTrace()
+		for j := 0; j < 8; j++ {
+			trace.AddSpan(NewSpan())
+		}
+		obs.Enqueue(&Entry{TraceID: "trace-1", State: "success"})
+		obs.Flush(1, discardWriter{})
+	}
+}
+
+func BenchmarkRequestPooled(b *testing.B) {
+	obs := NewObserver(&Config{BufferSize: 64, ReleaseEntries: true})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		trace := AcquireTrace()
+		for j := 0; j < 8; j++ {
+			trace.AddSpan(AcquireSpan())
+		}
+		entry := AcquireEntry()
+		entry.TraceID = "trace-1"
+		entry.State = "success"
+		obs.Enqueue(entry)
+		obs.Flush(1, discardWriter{})
+		ReleaseTrace(trace)
+	}
+}
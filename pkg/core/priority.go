@@ -0,0 +1,52 @@
+package core
+
+// Priority represents how important it is to keep an entry under buffer pressure
+type Priority int
+
+const (
+	// PriorityLow is for successful requests without warnings
+	PriorityLow Priority = iota
+	// PriorityNormal is for requests still processing or with warnings
+	PriorityNormal
+	// PriorityHigh is for failed requests and entries with error events
+	PriorityHigh
+
+	priorityCount = 3
+)
+
+// String returns the priority name
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// EntryPriority derives an entry's priority from its state and event levels
+func EntryPriority(entry *Entry) Priority {
+	if entry.State == "error" || entry.Error != nil {
+		return PriorityHigh
+	}
+	priority := PriorityLow
+	if entry.State != "success" {
+		priority = PriorityNormal
+	}
+	for _, span := range entry.Spans {
+		if span.Event == nil {
+			continue
+		}
+		switch span.Event.Level {
+		case "error":
+			return PriorityHigh
+		case "warn":
+			priority = PriorityNormal
+		}
+	}
+	return priority
+}
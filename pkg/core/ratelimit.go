@@ -0,0 +1,217 @@
+package core
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitRate     = 1
+	defaultRateLimitBurst    = 10
+	defaultRateLimitMaxKeys  = 1000
+	defaultRateLimitInterval = time.Minute
+
+	// maxPendingSuppressions bounds the suppressions kept for evicted keys,
+	// further evictions are merged into a single overflow suppression
+	maxPendingSuppressions = 100
+	overflowLevel          = "warn"
+	overflowMessage        = "rate limited events of evicted messages"
+)
+
+// RateLimitConfig represents per-message rate limit configuration
+type RateLimitConfig struct {
+	Rate            float64       // events per second allowed for each level+message, defaults to 1
+	Burst           int           // events allowed at once before limiting starts
+	MaxKeys         int           // size of the key table, least recently used keys are evicted
+	SummaryInterval time.Duration // how often a message's suppressed count is reported on a trace that hit the limit, defaults to 1m
+}
+
+// Suppression represents events dropped by the rate limiter
+type Suppression struct {
+	Level   string
+	Message string
+	Count   uint64
+}
+
+// SummaryMessage returns the message for the summary event
+func (s Suppression) SummaryMessage() string {
+	return fmt.Sprintf("%s (suppressed %d similar events)", s.Message, s.Count)
+}
+
+type rateLimitKey struct {
+	level   string
+	message string
+}
+
+type bucket struct {
+	key        rateLimitKey
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+	summarized time.Time // when the suppressed count was last reported
+}
+
+// RateLimiter limits repeated log events using a token bucket per level+message
+type RateLimiter struct {
+	mu       sync.Mutex
+	config   RateLimitConfig
+	buckets  map[rateLimitKey]*list.Element
+	lru      *list.List
+	pending  []Suppression // suppressions of evicted keys
+	overflow uint64        // suppressed events of evicted keys beyond maxPendingSuppressions
+	now      func() time.Time
+}
+
+// NewRateLimiter creates a new rate limiter
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Rate <= 0 {
+		config.Rate = defaultRateLimitRate
+	}
+	if config.SummaryInterval <= 0 {
+		config.SummaryInterval = defaultRateLimitInterval
+	}
+	if config.Burst <= 0 {
+		config.Burst = defaultRateLimitBurst
+	}
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = defaultRateLimitMaxKeys
+	}
+	return &RateLimiter{
+		config:  config,
+		buckets: make(map[rateLimitKey]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether an event should be emitted.
+// When an event is allowed after others with the same key were suppressed,
+// the suppression is returned so the caller can emit a summary event.
+func (l *RateLimiter) Allow(level, message string) (bool, *Suppression) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := rateLimitKey{level: level, message: message}
+	elem, ok := l.buckets[key]
+	if !ok {
+		l.evict()
+		elem = l.lru.PushFront(&bucket{
+			key:        key,
+			tokens:     float64(l.config.Burst),
+			last:       now,
+			summarized: now,
+		})
+		l.buckets[key] = elem
+	} else {
+		l.lru.MoveToFront(elem)
+	}
+
+	b := elem.Value.(*bucket)
+	b.tokens += now.Sub(b.last).Seconds() * l.config.Rate
+	if b.tokens > float64(l.config.Burst) {
+		b.tokens = float64(l.config.Burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		b.suppressed++
+		return false, nil
+	}
+	b.tokens--
+
+	if b.suppressed == 0 {
+		return true, nil
+	}
+	suppression := &Suppression{Level: level, Message: message, Count: b.suppressed}
+	b.suppressed = 0
+	b.summarized = now
+	return true, suppression
+}
+
+// Drain returns and resets all pending suppressions, including those of evicted keys.
+// It should be called periodically so suppressed events are reported even
+// when the message does not occur again.
+func (l *RateLimiter) Drain() []Suppression {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	result := l.pending
+	l.pending = nil
+	if l.overflow > 0 {
+		result = append(result, Suppression{Level: overflowLevel, Message: overflowMessage, Count: l.overflow})
+		l.overflow = 0
+	}
+	for elem := l.lru.Front(); elem != nil; elem = elem.Next() {
+		b := elem.Value.(*bucket)
+		if b.suppressed > 0 {
+			result = append(result, Suppression{Level: b.key.level, Message: b.key.message, Count: b.suppressed})
+			b.suppressed = 0
+			b.summarized = now
+		}
+	}
+	return result
+}
+
+// drainDue returns and resets the suppressions of keys whose count was last
+// reported at least SummaryInterval ago, including those of evicted keys
+func (l *RateLimiter) drainDue(keys []rateLimitKey) []Suppression {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var result []Suppression
+	for _, key := range keys {
+		elem, ok := l.buckets[key]
+		if !ok {
+			result = l.drainPending(key, result)
+			continue
+		}
+		b := elem.Value.(*bucket)
+		if b.suppressed > 0 && now.Sub(b.summarized) >= l.config.SummaryInterval {
+			result = append(result, Suppression{Level: key.level, Message: key.message, Count: b.suppressed})
+			b.suppressed = 0
+			b.summarized = now
+		}
+	}
+	return result
+}
+
+// drainPending moves the pending suppression of an evicted key to result
+func (l *RateLimiter) drainPending(key rateLimitKey, result []Suppression) []Suppression {
+	for i, s := range l.pending {
+		if s.Level == key.level && s.Message == key.message {
+			l.pending = append(l.pending[:i], l.pending[i+1:]...)
+			return append(result, s)
+		}
+	}
+	return result
+}
+
+// Len returns the number of tracked keys
+func (l *RateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// evict removes the least recently used key when the table is full
+func (l *RateLimiter) evict() {
+	if l.lru.Len() < l.config.MaxKeys {
+		return
+	}
+	elem := l.lru.Back()
+	b := elem.Value.(*bucket)
+	switch {
+	case b.suppressed == 0:
+	case len(l.pending) < maxPendingSuppressions:
+		l.pending = append(l.pending, Suppression{Level: b.key.level, Message: b.key.message, Count: b.suppressed})
+	default:
+		l.overflow += b.suppressed
+	}
+	l.lru.Remove(elem)
+	delete(l.buckets, b.key)
+}
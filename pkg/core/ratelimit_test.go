@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Token bucket", func(t *testing.T) {
+		now := time.Now()
+		limiter := NewRateLimiter(RateLimitConfig{Rate: 1, Burst: 2})
+		limiter.now = func() time.Time { return now }
+
+		allowed, suppression := limiter.Allow("error", "db down")
+		assert.True(t, allowed)
+		assert.Nil(t, suppression)
+		allowed, _ = limiter.Allow("error", "db down")
+		assert.True(t, allowed)
+
+		for i := 0; i < 5; i++ {
+			allowed, _ = limiter.Allow("error", "db down")
+			assert.False(t, allowed, "Should suppress events over burst")
+		}
+
+		allowed, _ = limiter.Allow("warn", "db down")
+		assert.True(t, allowed, "Different level should have its own bucket")
+
+		now = now.Add(time.Second)
+		allowed, suppression = limiter.Allow("error", "db down")
+		assert.True(t, allowed, "Should allow after refill")
+		assert.Equal(t, &Suppression{Level: "error", Message: "db down", Count: 5}, suppression)
+		assert.Equal(t, "db down (suppressed 5 similar events)", suppression.SummaryMessage())
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimitConfig{Rate: 0, Burst: 1})
+		limiter.Allow("error", "a")
+		limiter.Allow("error", "a")
+		limiter.Allow("error", "a")
+
+		assert.Equal(t, []Suppression{{Level: "error", Message: "a", Count: 2}}, limiter.Drain())
+		assert.Empty(t, limiter.Drain(), "Drain should reset counts")
+	})
+
+	t.Run("Bounded keys", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimitConfig{Rate: 0, Burst: 1, MaxKeys: 3})
+		limiter.Allow("error", "evicted")
+		limiter.Allow("error", "evicted")
+		for i := 0; i < 10; i++ {
+			limiter.Allow("info", fmt.Sprintf("message %d", i))
+		}
+
+		assert.Equal(t, 3, limiter.Len(), "Key table should be bounded")
+		assert.Contains(t, limiter.Drain(), Suppression{Level: "error", Message: "evicted", Count: 1},
+			"Suppressions of evicted keys should be kept")
+	})
+
+	t.Run("Bounded pending suppressions", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimitConfig{Burst: 1, MaxKeys: 1})
+		for i := 0; i < maxPendingSuppressions+50; i++ {
+			message := fmt.Sprintf("message %d", i)
+			limiter.Allow("error", message)
+			limiter.Allow("error", message)
+		}
+		limiter.Allow("info", "last")
+
+		suppressions := limiter.Drain()
+		assert.Len(t, suppressions, maxPendingSuppressions+1)
+		assert.Equal(t, Suppression{Level: overflowLevel, Message: overflowMessage, Count: 50}, suppressions[maxPendingSuppressions],
+			"Evictions past the cap should be merged")
+	})
+
+	t.Run("Default rate", func(t *testing.T) {
+		now := time.Now()
+		limiter := NewRateLimiter(RateLimitConfig{Burst: 1})
+		limiter.now = func() time.Time { return now }
+		limiter.Allow("error", "x")
+		allowed, _ := limiter.Allow("error", "x")
+		assert.False(t, allowed)
+
+		now = now.Add(time.Second)
+		allowed, _ = limiter.Allow("error", "x")
+		assert.True(t, allowed, "Zero rate should not mute a message forever")
+	})
+
+	t.Run("Summary events", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 1, RateLimit: &RateLimitConfig{Burst: 1, SummaryInterval: time.Minute}})
+		now := time.Now()
+		obs.RateLimiter().now = func() time.Time { return now }
+
+		trace := obs.StartTrace(SamplingParameters{TraceID: "trace-1"})
+		for i := 0; i < 3; i++ {
+			trace.AddEvent(&Event{Level: "error", Message: "db down", Time: now})
+		}
+		trace.End("error")
+		assert.Len(t, trace.Events, 1, "Suppressed events should be dropped before the interval")
+
+		trace = obs.StartTrace(SamplingParameters{TraceID: "trace-2"})
+		trace.AddEvent(&Event{Level: "error", Message: "db down", Time: now})
+		now = now.Add(time.Minute)
+		unrelated := obs.StartTrace(SamplingParameters{TraceID: "trace-3"})
+		unrelated.End("success")
+		assert.Empty(t, unrelated.Events, "Traces that hit no limit should get no summaries")
+
+		trace.End("error")
+		if assert.Len(t, trace.Events, 1) {
+			assert.Equal(t, "error", trace.Events[0].Level)
+			assert.Equal(t, "db down (suppressed 3 similar events)", trace.Events[0].Message)
+		}
+	})
+
+	t.Run("Observer", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 1})
+		allowed, _ := obs.AllowEvent("error", "x")
+		assert.True(t, allowed)
+		assert.Nil(t, obs.RateLimiter())
+
+		obs = NewObserver(&Config{BufferSize: 1, RateLimit: &RateLimitConfig{Rate: 0, Burst: 1}})
+		allowed, _ = obs.AllowEvent("error", "x")
+		assert.True(t, allowed)
+		allowed, _ = obs.AllowEvent("error", "x")
+		assert.False(t, allowed)
+	})
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// SamplingParameters represents the data a sampler sees when a trace starts
+type SamplingParameters struct {
+	TraceID       string
+	Method        string
+	Path          string
+	ParentSampled *bool // incoming sampled flag, nil when the request carries none
+}
+
+// Sampler decides whether a trace records spans
+type Sampler interface {
+	ShouldSample(params SamplingParameters) bool
+}
+
+// SamplerFunc adapts a function to the Sampler interface
+type SamplerFunc func(params SamplingParameters) bool
+
+// ShouldSample calls f(params)
+func (f SamplerFunc) ShouldSample(params SamplingParameters) bool {
+	return f(params)
+}
+
+// AlwaysSample returns a sampler that records every trace
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) bool { return true })
+}
+
+// NeverSample returns a sampler that records no traces
+func NeverSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) bool { return false })
+}
+
+// RatioSampler samples a fraction of traces.
+// The decision is derived from the trace ID so every service sharing
+// a trace ID makes the same choice.
+type RatioSampler struct {
+	threshold uint64
+}
+
+// NewRatioSampler creates a sampler keeping ratio (0..1) of traces
+func NewRatioSampler(ratio float64) *RatioSampler {
+	switch {
+	case ratio <= 0:
+		return &RatioSampler{threshold: 0}
+	case ratio >= 1:
+		return &RatioSampler{threshold: math.MaxUint64}
+	}
+	return &RatioSampler{threshold: uint64(ratio * math.MaxUint64)}
+}
+
+// ShouldSample implements Sampler
+func (s *RatioSampler) ShouldSample(params SamplingParameters) bool {
+	if s.threshold == math.MaxUint64 {
+		return true
+	}
+	if params.TraceID == "" {
+		return rand.Uint64() < s.threshold
+	}
+	h := fnv.New64a()
+	h.Write([]byte(params.TraceID))
+	return mix64(h.Sum64()) < s.threshold
+}
+
+// mix64 spreads fnv output over the full range, similar trace IDs
+// otherwise differ only in the low bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// SamplingRule represents a per-route sampling rule.
+// Empty Method or PathPrefix match any request.
+type SamplingRule struct {
+	Method     string
+	PathPrefix string
+	Sampler    Sampler
+}
+
+func (r SamplingRule) match(params SamplingParameters) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, params.Method) {
+		return false
+	}
+	return strings.HasPrefix(params.Path, r.PathPrefix)
+}
+
+// RuleSampler applies the first matching rule, falling back to a default sampler
+type RuleSampler struct {
+	rules    []SamplingRule
+	fallback Sampler
+}
+
+// NewRuleSampler creates a rule based sampler
+func NewRuleSampler(fallback Sampler, rules ...SamplingRule) *RuleSampler {
+	if fallback == nil {
+		fallback = AlwaysSample()
+	}
+	return &RuleSampler{
+		rules:    rules,
+		fallback: fallback,
+	}
+}
+
+// ShouldSample implements Sampler
+func (s *RuleSampler) ShouldSample(params SamplingParameters) bool {
+	for _, rule := range s.rules {
+		if rule.match(params) {
+			return rule.Sampler.ShouldSample(params)
+		}
+	}
+	return s.fallback.ShouldSample(params)
+}
+
+// ParentBasedSampler honours the incoming sampled flag and
+// delegates root traces to another sampler
+type ParentBasedSampler struct {
+	root Sampler
+}
+
+// NewParentBasedSampler creates a parent based sampler
+func NewParentBasedSampler(root Sampler) *ParentBasedSampler {
+	if root == nil {
+		root = AlwaysSample()
+	}
+	return &ParentBasedSampler{root: root}
+}
+
+// ShouldSample implements Sampler
+func (s *ParentBasedSampler) ShouldSample(params SamplingParameters) bool {
+	if params.ParentSampled != nil {
+		return *params.ParentSampled
+	}
+	return s.root.ShouldSample(params)
+}
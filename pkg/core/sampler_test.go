@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	t.Run("Always and never", func(t *testing.T) {
+		assert.True(t, AlwaysSample().ShouldSample(SamplingParameters{}))
+		assert.False(t, NeverSample().ShouldSample(SamplingParameters{}))
+	})
+
+	t.Run("Ratio", func(t *testing.T) {
+		sampler := NewRatioSampler(0.25)
+		sampled := 0
+		for i := 0; i < 10000; i++ {
+			if sampler.ShouldSample(SamplingParameters{TraceID: fmt.Sprintf("trace-%d", i)}) {
+				sampled++
+			}
+		}
+		assert.InDelta(t, 2500, sampled, 250, "Should sample about a quarter of traces")
+
+		params := SamplingParameters{TraceID: "trace-stable"}
+		first := sampler.ShouldSample(params)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, first, sampler.ShouldSample(params), "Decision should be stable per trace ID")
+		}
+
+		assert.True(t, NewRatioSampler(1).ShouldSample(params))
+		assert.False(t, NewRatioSampler(0).ShouldSample(params))
+	})
+
+	t.Run("Rules", func(t *testing.T) {
+		sampler := NewRuleSampler(AlwaysSample(),
+			SamplingRule{PathPrefix: "/health", Sampler: NeverSample()},
+			SamplingRule{Method: "POST", PathPrefix: "/api/orders", Sampler: AlwaysSample()},
+			SamplingRule{PathPrefix: "/api", Sampler: NeverSample()},
+		)
+
+		assert.False(t, sampler.ShouldSample(SamplingParameters{Method: "GET", Path: "/health"}))
+		assert.True(t, sampler.ShouldSample(SamplingParameters{Method: "post", Path: "/api/orders/1"}))
+		assert.False(t, sampler.ShouldSample(SamplingParameters{Method: "GET", Path: "/api/orders/1"}))
+		assert.True(t, sampler.ShouldSample(SamplingParameters{Method: "GET", Path: "/"}))
+	})
+
+	t.Run("Parent based", func(t *testing.T) {
+		sampler := NewParentBasedSampler(NeverSample())
+		yes, no := true, false
+
+		assert.True(t, sampler.ShouldSample(SamplingParameters{ParentSampled: &yes}))
+		assert.False(t, sampler.ShouldSample(SamplingParameters{ParentSampled: &no}))
+		assert.False(t, sampler.ShouldSample(SamplingParameters{}), "Root traces should use root sampler")
+	})
+
+	t.Run("Observer", func(t *testing.T) {
+		obs := NewObserver(&Config{BufferSize: 1, Sampler: NeverSample()})
+		trace := obs.StartTrace(SamplingParameters{TraceID: "trace-1", Method: "GET", Path: "/users"})
+		trace.AddSpan(NewSpan())
+
+		assert.False(t, trace.Sampled())
+		assert.Empty(t, trace.Spans, "Unsampled trace should not record spans")
+		assert.Equal(t, "GET", trace.Method)
+		assert.Equal(t, "/users", trace.OriginalPath)
+		assert.False(t, trace.StartTime.IsZero(), "Unsampled trace should still be timed")
+
+		obs = NewObserver(nil)
+		trace = obs.StartTrace(SamplingParameters{})
+		trace.AddSpan(NewSpan())
+		assert.True(t, trace.Sampled())
+		assert.Len(t, trace.Spans, 1)
+	})
+
+	t.Run("Trace literal", func(t *testing.T) {
+		trace := &Trace{TraceID: "trace-1"}
+		trace.AddSpan(NewSpan())
+		trace.AddEvent(&Event{Level: "info", Message: "started"})
+
+		assert.True(t, trace.Sampled(), "Zero value trace should be sampled")
+		assert.Len(t, trace.Spans, 1)
+		assert.Len(t, trace.Events, 1)
+	})
+}
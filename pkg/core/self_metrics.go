@@ -0,0 +1,80 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SelfMetrics holds counters and gauges describing the observer itself,
+// such as output failures and circuit states. A nil *SelfMetrics
+// discards all updates so components can report unconditionally.
+type SelfMetrics struct {
+	mu     sync.RWMutex
+	values map[string]*atomic.Int64
+}
+
+// NewSelfMetrics creates an empty metrics set
+func NewSelfMetrics() *SelfMetrics {
+	return &SelfMetrics{values: make(map[string]*atomic.Int64)}
+}
+
+// Add adds delta to the named counter
+func (m *SelfMetrics) Add(name string, delta int64) {
+	if m == nil {
+		return
+	}
+	m.value(name).Add(delta)
+}
+
+// Set sets the named gauge
+func (m *SelfMetrics) Set(name string, value int64) {
+	if m == nil {
+		return
+	}
+	m.value(name).Store(value)
+}
+
+// Get returns the current value of a metric, 0 if it was never reported
+func (m *SelfMetrics) Get(name string) int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.RLock()
+	v, ok := m.values[name]
+	m.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return v.Load()
+}
+
+// Snapshot returns a copy of all metrics
+func (m *SelfMetrics) Snapshot() map[string]int64 {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string]int64, len(m.values))
+	for name, v := range m.values {
+		snapshot[name] = v.Load()
+	}
+	return snapshot
+}
+
+func (m *SelfMetrics) value(name string) *atomic.Int64 {
+	m.mu.RLock()
+	v, ok := m.values[name]
+	m.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok = m.values[name]; !ok {
+		v = new(atomic.Int64)
+		m.values[name] = v
+	}
+	return v
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfMetrics(t *testing.T) {
+	metrics := NewSelfMetrics()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				metrics.Add("writes", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	metrics.Set("state", 2)
+	metrics.Set("state", 1)
+
+	assert.Equal(t, int64(800), metrics.Get("writes"))
+	assert.Equal(t, map[string]int64{"writes": 800, "state": 1}, metrics.Snapshot())
+	assert.Zero(t, metrics.Get("missing"))
+
+	var disabled *SelfMetrics
+	disabled.Add("writes", 1)
+	assert.Zero(t, disabled.Get("writes"), "Nil metrics should discard updates")
+	assert.NotNil(t, NewObserver(nil).SelfMetrics())
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Rough JSON overhead used by EstimateSize
+const (
+	entryOverhead = 256 // field names, timestamps and punctuation of an entry
+	spanOverhead  = 160 // field names, timestamps and punctuation of a span
+	fieldOverhead = 6   // quotes, colon and comma around a map field
+)
+
+// EstimateSize returns the approximate encoded size of an entry in bytes.
+// It is meant for budgets and batching, not exact accounting.
+func EstimateSize(entry *Entry) int {
+	size := entryOverhead +
+		len(entry.TraceID) + len(entry.RequestID) + len(entry.UserID) +
+		len(entry.State) + len(entry.Method) + len(entry.OriginalPath)
+	for _, span := range entry.Spans {
+		size += spanOverhead + len(span.Function) + len(span.SpanID)
+		size += estimateMapSize(span.Input) + estimateMapSize(span.Output)
+		if span.Event != nil {
+			size += len(span.Event.Level) + len(span.Event.Message)
+		}
+	}
+	if entry.Error != nil {
+		size += len(entry.Error.Message)
+	}
+	return size
+}
+
+func estimateMapSize(m map[string]interface{}) int {
+	size := 2
+	for k, v := range m {
+		size += fieldOverhead + len(k) + estimateValueSize(v)
+	}
+	return size
+}
+
+func estimateValueSize(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 4
+	case string:
+		return len(val) + 2
+	case []byte:
+		return len(val)*4/3 + 2
+	case bool:
+		return 5
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return 8
+	case map[string]interface{}:
+		return estimateMapSize(val)
+	case []interface{}:
+		size := 2
+		for _, item := range val {
+			size += 1 + estimateValueSize(item)
+		}
+		return size
+	case []string:
+		size := 2
+		for _, item := range val {
+			size += 3 + len(item)
+		}
+		return size
+	default:
+		return len(fmt.Sprint(val)) + 2
+	}
+}
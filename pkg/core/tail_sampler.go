@@ -0,0 +1,61 @@
+package core
+
+import (
+	"time"
+)
+
+// Tail sampling reasons
+const (
+	SampleReasonError   = "error"
+	SampleReasonSlow    = "slow"
+	SampleReasonRatio   = "ratio"
+	SampleReasonDropped = "dropped"
+)
+
+// SamplingDecision represents the outcome of tail sampling
+type SamplingDecision struct {
+	Sampled bool
+	Reason  string
+}
+
+// TailSamplingParameters represents the data a tail sampler sees when a trace completes
+type TailSamplingParameters struct {
+	TraceID  string
+	State    string
+	Duration time.Duration
+}
+
+// TailSamplerConfig represents tail sampler configuration
+type TailSamplerConfig struct {
+	SlowThreshold time.Duration // traces at or above this duration are kept, zero disables
+	Ratio         float64       // fraction of remaining traces to keep (0..1)
+}
+
+// TailSampler decides whether a completed trace is emitted.
+// Error and slow traces are always kept, a ratio of the rest is kept.
+type TailSampler struct {
+	config TailSamplerConfig
+	ratio  *RatioSampler
+}
+
+// NewTailSampler creates a new tail sampler
+func NewTailSampler(config TailSamplerConfig) *TailSampler {
+	return &TailSampler{
+		config: config,
+		ratio:  NewRatioSampler(config.Ratio),
+	}
+}
+
+// Decide returns the sampling decision for a completed trace
+func (s *TailSampler) Decide(params TailSamplingParameters) SamplingDecision {
+	if params.State == "error" {
+		return SamplingDecision{Sampled: true, Reason: SampleReasonError}
+	}
+	if s.config.SlowThreshold > 0 && params.Duration >= s.config.SlowThreshold {
+		return SamplingDecision{Sampled: true, Reason: SampleReasonSlow}
+	}
+	if s.ratio.ShouldSample(SamplingParameters{TraceID: params.TraceID}) {
+		return SamplingDecision{Sampled: true, Reason: SampleReasonRatio}
+	}
+	return SamplingDecision{Sampled: false, Reason: SampleReasonDropped}
+}
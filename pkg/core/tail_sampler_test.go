@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailSampler(t *testing.T) {
+	sampler := NewTailSampler(TailSamplerConfig{
+		SlowThreshold: 500 * time.Millisecond,
+		Ratio:         0,
+	})
+
+	decision := sampler.Decide(TailSamplingParameters{TraceID: "trace-1", State: "error"})
+	assert.Equal(t, SamplingDecision{Sampled: true, Reason: SampleReasonError}, decision, "Error traces should be kept")
+
+	decision = sampler.Decide(TailSamplingParameters{TraceID: "trace-2", State: "success", Duration: time.Second})
+	assert.Equal(t, SamplingDecision{Sampled: true, Reason: SampleReasonSlow}, decision, "Slow traces should be kept")
+
+	decision = sampler.Decide(TailSamplingParameters{TraceID: "trace-3", State: "success", Duration: time.Millisecond})
+	assert.Equal(t, SamplingDecision{Sampled: false, Reason: SampleReasonDropped}, decision, "Fast successful traces should be dropped")
+
+	t.Run("Ratio", func(t *testing.T) {
+		sampler := NewTailSampler(TailSamplerConfig{Ratio: 0.5})
+		kept := 0
+		for i := 0; i < 1000; i++ {
+			decision := sampler.Decide(TailSamplingParameters{TraceID: fmt.Sprintf("trace-%d", i), State: "success"})
+			if decision.Sampled {
+				assert.Equal(t, SampleReasonRatio, decision.Reason)
+				kept++
+			}
+		}
+		assert.InDelta(t, 500, kept, 75, "Should keep about half of the remaining traces")
+	})
+}
+
+func TestObserverTailSampling(t *testing.T) {
+	obs := NewObserver(&Config{
+		BufferSize: 10,
+		TailSampler: NewTailSampler(TailSamplerConfig{
+			SlowThreshold: 500 * time.Millisecond,
+		}),
+	})
+
+	failed := &Entry{TraceID: "trace-1", State: "error", Duration: 0.01}
+	slow := &Entry{TraceID: "trace-2", State: "success", Duration: 2}
+	assert.True(t, obs.Enqueue(failed))
+	assert.True(t, obs.Enqueue(slow))
+	assert.False(t, obs.Enqueue(&Entry{TraceID: "trace-3", State: "success", Duration: 0.01}), "Fast successful trace should be dropped")
+	assert.True(t, obs.Enqueue(&Entry{TraceID: "trace-4", State: "processing"}), "Unfinished entries should bypass the sampler")
+
+	entries := obs.Dequeue(nil, 10)
+	assert.Len(t, entries, 3)
+	assert.Contains(t, entries, slow)
+	assert.Equal(t, &SamplingDecision{Sampled: true, Reason: SampleReasonError}, failed.Sampling)
+	assert.Equal(t, &SamplingDecision{Sampled: true, Reason: SampleReasonSlow}, slow.Sampling)
+	assert.Zero(t, obs.BufferStats().Dropped, "Sampled out entries are not buffer drops")
+
+	metrics := obs.SelfMetrics()
+	assert.Equal(t, int64(1), metrics.Get("sampler.tail.error"))
+	assert.Equal(t, int64(1), metrics.Get("sampler.tail.slow"))
+	assert.Equal(t, int64(1), metrics.Get("sampler.tail.dropped"))
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"slices"
+	"sort"
 	"time"
 )
 
@@ -99,6 +101,13 @@ import (
 // 	return e
 // }
 
+// Event represents a log event recorded on a trace
+type Event struct {
+	Level   string // debug, info, warn, error
+	Message string
+	Time    time.Time
+}
+
 type Span struct {
 }
 
@@ -107,19 +116,99 @@ func NewSpan() *Span {
 }
 
 type Trace struct {
-	Spans     []*Span
-	TraceID   string
-	RequestID string
-	UserID    string
-	StartTime time.Time
-	EndTime   time.Time
-    Duration  float64
-    State     string
-    Method    string
-    OriginalPath string
-    
+	Spans        []*Span
+	TraceID      string
+	RequestID    string
+	UserID       string
+	StartTime    time.Time
+	EndTime      time.Time
+	Duration     float64
+	State        string
+	Method       string
+	OriginalPath string
+	Events       []*Event
+
+	dropped    bool // set when the sampler dropped this trace, so the zero value records
+	debug      *debugBuffer
+	limiter    *RateLimiter
+	suppressed []rateLimitKey // rate limited messages this trace hit
 }
 
 func NewTrace() *Trace {
 	return &Trace{}
 }
+
+// Sampled reports whether the trace records spans and events
+func (t *Trace) Sampled() bool {
+	return !t.dropped
+}
+
+// AddSpan records a span, unsampled traces keep no spans
+func (t *Trace) AddSpan(span *Span) {
+	if t.dropped {
+		return
+	}
+	t.Spans = append(t.Spans, span)
+}
+
+// AddEvent records an event. Events over the rate limit are dropped and
+// reported later as a summary event, see RateLimitConfig. Debug events are
+// held back when the trace buffers debug output, see DebugBufferConfig.
+func (t *Trace) AddEvent(event *Event) {
+	if t.dropped {
+		return
+	}
+	if t.limiter != nil {
+		allowed, suppression := t.limiter.Allow(event.Level, event.Message)
+		if !allowed {
+			t.addSuppressed(rateLimitKey{level: event.Level, message: event.Message})
+			return
+		}
+		if suppression != nil {
+			t.addSummary(*suppression, event.Time)
+		}
+	}
+	if event.Level == "debug" && t.debug != nil {
+		t.debug.push(event)
+		return
+	}
+	t.Events = append(t.Events, event)
+}
+
+// addSuppressed remembers a rate limited message so End can report it
+func (t *Trace) addSuppressed(key rateLimitKey) {
+	if !slices.Contains(t.suppressed, key) {
+		t.suppressed = append(t.suppressed, key)
+	}
+}
+
+// addSummary records a summary event for suppressed events
+func (t *Trace) addSummary(s Suppression, at time.Time) {
+	t.Events = append(t.Events, &Event{Level: s.Level, Message: s.SummaryMessage(), Time: at})
+}
+
+// End marks the trace as completed with the given state, attaches due
+// summaries of the rate limited messages this trace hit and attaches or
+// discards held debug events
+func (t *Trace) End(state string) {
+	t.EndTime = time.Now()
+	t.Duration = t.EndTime.Sub(t.StartTime).Seconds()
+	t.State = state
+
+	if len(t.suppressed) > 0 {
+		for _, s := range t.limiter.drainDue(t.suppressed) {
+			t.addSummary(s, t.EndTime)
+		}
+	}
+
+	if t.debug == nil {
+		return
+	}
+	if t.debug.flush(t) {
+		t.Events = append(t.Events, t.debug.drain()...)
+		sort.SliceStable(t.Events, func(i, j int) bool {
+			return t.Events[i].Time.Before(t.Events[j].Time)
+		})
+	}
+	t.debug = nil
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultBatchWait = time.Second
+)
+
+// BatchConfig represents batching limits for an output.
+// A batch is written once any limit is reached.
+type BatchConfig struct {
+	MaxCount int                   // maximum entries per batch
+	MaxBytes int                   // maximum estimated encoded bytes per batch, 0 disables
+	MaxWait  time.Duration         // maximum time an entry waits for a batch to fill
+	Size     func(*core.Entry) int // size estimator, defaults to core.EstimateSize
+}
+
+type pendingEntry struct {
+	entry *core.Entry
+	size  int
+	added time.Time
+}
+
+// Batcher accumulates entries until a count, byte or time limit is reached.
+// It is not safe for concurrent use.
+type Batcher struct {
+	config  BatchConfig
+	pending []pendingEntry
+	bytes   int
+}
+
+// NewBatcher creates a new batcher
+func NewBatcher(config BatchConfig) *Batcher {
+	if config.MaxCount <= 0 {
+		config.MaxCount = defaultBatchSize
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = defaultBatchWait
+	}
+	if config.Size == nil {
+		config.Size = core.EstimateSize
+	}
+	return &Batcher{
+		config: config,
+	}
+}
+
+// Add adds an entry to the pending batch
+func (b *Batcher) Add(entry *core.Entry, now time.Time) {
+	size := b.config.Size(entry)
+	b.pending = append(b.pending, pendingEntry{entry: entry, size: size, added: now})
+	b.bytes += size
+}
+
+// Ready reports whether a batch should be taken
+func (b *Batcher) Ready(now time.Time) bool {
+	if len(b.pending) == 0 {
+		return false
+	}
+	if len(b.pending) >= b.config.MaxCount {
+		return true
+	}
+	if b.config.MaxBytes > 0 && b.bytes >= b.config.MaxBytes {
+		return true
+	}
+	return !now.Before(b.Deadline())
+}
+
+// Deadline returns when the oldest pending entry reaches MaxWait,
+// the zero time when nothing is pending
+func (b *Batcher) Deadline() time.Time {
+	if len(b.pending) == 0 {
+		return time.Time{}
+	}
+	return b.pending[0].added.Add(b.config.MaxWait)
+}
+
+// Take appends the oldest entries that fit within the limits to dst and returns it.
+// At least one entry is taken, so an entry larger than MaxBytes is sent alone.
+func (b *Batcher) Take(dst []*core.Entry) []*core.Entry {
+	n, bytes := 0, 0
+	for n < len(b.pending) && n < b.config.MaxCount {
+		size := b.pending[n].size
+		if n > 0 && b.config.MaxBytes > 0 && bytes+size > b.config.MaxBytes {
+			break
+		}
+		dst = append(dst, b.pending[n].entry)
+		bytes += size
+		n++
+	}
+
+	remaining := copy(b.pending, b.pending[n:])
+	clear(b.pending[remaining:])
+	b.pending = b.pending[:remaining]
+	b.bytes -= bytes
+	return dst
+}
+
+// Len returns the number of pending entries
+func (b *Batcher) Len() int {
+	return len(b.pending)
+}
+
+// Bytes returns the estimated size of pending entries
+func (b *Batcher) Bytes() int {
+	return b.bytes
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	now := time.Now()
+	sizeOf := func(entry *core.Entry) int { return len(entry.TraceID) }
+
+	t.Run("Max count", func(t *testing.T) {
+		batcher := NewBatcher(BatchConfig{MaxCount: 2, MaxWait: time.Minute, Size: sizeOf})
+		entries := newQueueEntries(3)
+
+		batcher.Add(entries[0], now)
+		assert.False(t, batcher.Ready(now))
+		batcher.Add(entries[1], now)
+		batcher.Add(entries[2], now)
+		assert.True(t, batcher.Ready(now), "Batch should be ready at MaxCount")
+
+		assert.Equal(t, entries[:2], batcher.Take(nil))
+		assert.Equal(t, 1, batcher.Len())
+		assert.False(t, batcher.Ready(now))
+	})
+
+	t.Run("Max bytes", func(t *testing.T) {
+		batcher := NewBatcher(BatchConfig{MaxCount: 100, MaxBytes: 10, MaxWait: time.Minute, Size: sizeOf})
+		small := &core.Entry{TraceID: "1234"}
+		large := &core.Entry{TraceID: "123456789012"}
+
+		batcher.Add(small, now)
+		batcher.Add(small, now)
+		assert.False(t, batcher.Ready(now))
+		batcher.Add(small, now)
+		assert.True(t, batcher.Ready(now), "Batch should be ready at MaxBytes")
+		assert.Len(t, batcher.Take(nil), 2, "Batch should not exceed MaxBytes")
+		assert.Equal(t, 4, batcher.Bytes())
+
+		batcher.Take(nil)
+		batcher.Add(large, now)
+		assert.Equal(t, []*core.Entry{large}, batcher.Take(nil), "Oversized entry should be sent alone")
+	})
+
+	t.Run("Max wait", func(t *testing.T) {
+		batcher := NewBatcher(BatchConfig{MaxCount: 100, MaxWait: time.Second, Size: sizeOf})
+		assert.True(t, batcher.Deadline().IsZero())
+
+		batcher.Add(&core.Entry{}, now)
+		batcher.Add(&core.Entry{}, now.Add(500*time.Millisecond))
+		assert.Equal(t, now.Add(time.Second), batcher.Deadline(), "Deadline should follow oldest entry")
+		assert.False(t, batcher.Ready(now.Add(999*time.Millisecond)))
+		assert.True(t, batcher.Ready(now.Add(time.Second)))
+		assert.Len(t, batcher.Take(nil), 2)
+	})
+
+	t.Run("Queued output limits", func(t *testing.T) {
+		target := NewTestOutput()
+		queued := NewQueuedOutput(target, QueueConfig{
+			Batch: BatchConfig{MaxCount: 100, MaxBytes: 8, MaxWait: time.Hour, Size: sizeOf},
+		})
+		defer queued.Close()
+
+		queued.Write([]*core.Entry{{TraceID: "1234"}, {TraceID: "5678"}})
+		assert.Eventually(t, target.HasEntries, time.Second, 5*time.Millisecond,
+			"Batch should be written once MaxBytes is reached")
+	})
+}
@@ -0,0 +1,317 @@
+package output
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI colours used by the console formatter
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorGray   = "\x1b[90m"
+	colorBold   = "\x1b[1m"
+)
+
+// Box drawing characters for the span tree and timing bar
+const (
+	treeBranch = "\u251c\u2500 "
+	treeLast   = "\u2514\u2500 "
+	treeLine   = "\u2502  "
+	treeSpace  = "   "
+	barFull    = "\u2588"
+	barEmpty   = "\u00b7"
+)
+
+const (
+	defaultBarWidth     = 20
+	maxConsoleNameWidth = 40
+)
+
+// ConsoleFormatter renders entries for humans: a header line per request
+// followed by a tree of spans with a proportional timing bar and their events.
+// Control characters in entry values are escaped so they cannot forge lines
+// or colours. The header shows the entry state, entries carry no HTTP status.
+type ConsoleFormatter struct {
+	Color    bool // colour by state and level using ANSI escapes
+	BarWidth int  // width of the timing bar, defaults to 20
+}
+
+// NewConsoleFormatter creates a new console formatter
+func NewConsoleFormatter(color bool) *ConsoleFormatter {
+	return &ConsoleFormatter{Color: color, BarWidth: defaultBarWidth}
+}
+
+// Format appends the rendered entry to dst
+func (f *ConsoleFormatter) Format(dst []byte, entry *LogEntry) ([]byte, error) {
+	if entry == nil {
+		return dst, nil
+	}
+	start := consoleTime(entry.start, entry.StartTime)
+
+	// Header: time, method, path, state and duration
+	if !start.IsZero() {
+		dst = f.colored(dst, colorGray, start.Format("15:04:05"))
+		dst = append(dst, ' ')
+	}
+	if entry.Method != "" {
+		dst = f.colored(dst, colorBold, consoleText(entry.Method))
+		dst = append(dst, ' ')
+	}
+	if entry.OriginalPath != "" {
+		dst = appendConsoleText(dst, entry.OriginalPath)
+		dst = append(dst, ' ')
+	}
+	dst = f.colored(dst, stateColor(entry.State), consoleText(entry.State))
+	if entry.Duration != 0 {
+		dst = append(dst, ' ')
+		dst = appendConsoleDuration(dst, entry.Duration)
+	}
+	if entry.TraceID != "" {
+		dst = append(dst, ' ')
+		dst = f.colored(dst, colorGray, "trace="+consoleText(entry.TraceID))
+	}
+	if entry.Truncated {
+		dst = append(dst, ' ')
+		dst = f.colored(dst, colorYellow, "(truncated)")
+	}
+	dst = append(dst, '\n')
+
+	if len(entry.Metadata) > 0 {
+		dst = append(dst, "  "...)
+		dst = f.begin(dst, colorGray)
+		e := logfmtEncoder{dst: dst, start: len(dst)}
+		e.value(entry.Metadata)
+		// logfmt escapes ASCII controls, this also catches C1 controls
+		dst = f.end(appendConsoleText(dst, string(e.dst[len(dst):])))
+		dst = append(dst, '\n')
+	}
+	for _, msg := range entry.Errors {
+		dst = append(dst, "  "...)
+		dst = f.colored(dst, colorRed, "error: "+consoleText(msg))
+		dst = append(dst, '\n')
+	}
+
+	return f.appendSpans(dst, entry, start), nil
+}
+
+// appendSpans renders the span tree. Bars are placed relative to the
+// request start and scaled to the request duration, or to the longest
+// span end when the request has none.
+func (f *ConsoleFormatter) appendSpans(dst []byte, entry *LogEntry, start time.Time) []byte {
+	nameWidth, total := 0, entry.Duration
+	spans := make([]*SpanEntry, 0, len(entry.Spans))
+	names := make([]string, 0, len(entry.Spans))
+	for _, span := range entry.Spans {
+		if span == nil {
+			continue
+		}
+		spans = append(spans, span)
+		names = append(names, consoleText(span.Function))
+		nameWidth = max(nameWidth, min(utf8.RuneCountInString(names[len(names)-1]), maxConsoleNameWidth))
+		total = max(total, spanOffset(span, start)+span.Duration)
+	}
+
+	barWidth := f.BarWidth
+	if barWidth <= 0 {
+		barWidth = defaultBarWidth
+	}
+	for i, span := range spans {
+		last := i == len(spans)-1
+		dst = append(dst, "  "...)
+		if last {
+			dst = append(dst, treeLast...)
+		} else {
+			dst = append(dst, treeBranch...)
+		}
+
+		dst = appendPadded(dst, names[i], nameWidth)
+
+		// Timing bar
+		color := colorCyan
+		if span.Event != nil && span.Event.Level == "error" {
+			color = colorRed
+		}
+		from, to := 0, 0
+		if total > 0 {
+			offset := spanOffset(span, start)
+			from = int(offset / total * float64(barWidth))
+			to = int((offset + span.Duration) / total * float64(barWidth))
+			from = min(max(from, 0), barWidth-1)
+			to = min(max(to, from+1), barWidth)
+		}
+		dst = append(dst, " ["...)
+		dst = appendRepeat(dst, barEmpty, from)
+		dst = f.begin(dst, color)
+		dst = appendRepeat(dst, barFull, to-from)
+		dst = f.end(dst)
+		dst = appendRepeat(dst, barEmpty, barWidth-to)
+		dst = append(dst, "] "...)
+		dst = appendConsoleDuration(dst, span.Duration)
+		dst = append(dst, '\n')
+
+		if span.Event != nil {
+			dst = append(dst, "  "...)
+			if last {
+				dst = append(dst, treeSpace...)
+			} else {
+				dst = append(dst, treeLine...)
+			}
+			dst = append(dst, "  "...)
+			dst = f.colored(dst, levelColor(span.Event.Level), consoleText(span.Event.Level+": "+span.Event.Message))
+			dst = append(dst, '\n')
+		}
+	}
+	return dst
+}
+
+// appendPadded appends s cut or padded with spaces to width runes
+func appendPadded(dst []byte, s string, width int) []byte {
+	n := 0
+	for i := range s {
+		if n == width {
+			return append(dst, s[:i]...)
+		}
+		n++
+	}
+	dst = append(dst, s...)
+	return appendRepeat(dst, " ", width-n)
+}
+
+// spanOffset returns the seconds between the request start and the span start
+func spanOffset(span *SpanEntry, start time.Time) float64 {
+	if start.IsZero() {
+		return 0
+	}
+	spanStart := consoleTime(span.start, span.StartTime)
+	if spanStart.IsZero() {
+		return 0
+	}
+	return max(spanStart.Sub(start).Seconds(), 0)
+}
+
+// consoleTime returns t, the full precision time of entries converted from
+// core entries, or parses text for entries built directly
+func consoleTime(t time.Time, text string) time.Time {
+	if !t.IsZero() {
+		return t
+	}
+	t, _ = time.Parse(time.RFC3339Nano, text)
+	return t
+}
+
+// consoleText returns s with control characters and invalid UTF-8 escaped
+// as in a Go string literal, s itself when nothing needs escaping
+func consoleText(s string) string {
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return string(appendConsoleText(nil, s))
+		}
+	}
+	return s
+}
+
+// appendConsoleText appends s escaped like consoleText
+func appendConsoleText(dst []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, '\\', 'x', hexDigits[s[i]>>4], hexDigits[s[i]&0xF])
+		case unicode.IsPrint(r):
+			dst = append(dst, s[i:i+size]...)
+		default:
+			quoted := strconv.QuoteRune(r)
+			dst = append(dst, quoted[1:len(quoted)-1]...)
+		}
+		i += size
+	}
+	return dst
+}
+
+func (f *ConsoleFormatter) colored(dst []byte, color, s string) []byte {
+	dst = f.begin(dst, color)
+	dst = append(dst, s...)
+	return f.end(dst)
+}
+
+func (f *ConsoleFormatter) begin(dst []byte, color string) []byte {
+	if f.Color && color != "" {
+		dst = append(dst, color...)
+	}
+	return dst
+}
+
+func (f *ConsoleFormatter) end(dst []byte) []byte {
+	if f.Color {
+		dst = append(dst, colorReset...)
+	}
+	return dst
+}
+
+func stateColor(state string) string {
+	switch state {
+	case "success":
+		return colorGreen
+	case "error":
+		return colorRed
+	default:
+		return colorYellow
+	}
+}
+
+func levelColor(level string) string {
+	switch level {
+	case "error":
+		return colorRed
+	case "warn":
+		return colorYellow
+	case "info":
+		return colorCyan
+	default:
+		return colorGray
+	}
+}
+
+func appendRepeat(dst []byte, s string, n int) []byte {
+	for i := 0; i < n; i++ {
+		dst = append(dst, s...)
+	}
+	return dst
+}
+
+// appendConsoleDuration formats seconds with a unit suited to the magnitude
+func appendConsoleDuration(dst []byte, seconds float64) []byte {
+	switch {
+	case seconds >= 1:
+		dst = strconv.AppendFloat(dst, seconds, 'f', 2, 64)
+		return append(dst, 's')
+	case seconds >= 0.001:
+		dst = strconv.AppendFloat(dst, seconds*1e3, 'f', 1, 64)
+		return append(dst, "ms"...)
+	default:
+		dst = strconv.AppendFloat(dst, seconds*1e6, 'f', 0, 64)
+		return append(dst, "\u00b5s"...)
+	}
+}
+
+// isTerminal reports whether w is a terminal that should receive colour.
+// Colour is disabled when NO_COLOR is set or TERM is dumb.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
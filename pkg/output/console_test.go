@@ -0,0 +1,137 @@
+package output
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConsoleEntry() *LogEntry {
+	return &LogEntry{
+		TraceID:      "trace-1",
+		StartTime:    "2024-01-02T03:04:05Z",
+		Duration:     2,
+		State:        "error",
+		Method:       "POST",
+		OriginalPath: "/api/users",
+		Metadata:     map[string]interface{}{"user_id": "42"},
+		Errors:       []string{"db failed"},
+		Spans: []*SpanEntry{
+			{
+				Function:  "handler",
+				StartTime: "2024-01-02T03:04:05Z",
+				Duration:  2,
+				SpanID:    "1",
+			},
+			{
+				Function:  "repository.GetUser",
+				StartTime: "2024-01-02T03:04:06Z",
+				Duration:  0.5,
+				Event:     &EventEntry{Level: "error", Message: "not found"},
+				SpanID:    "2",
+			},
+		},
+	}
+}
+
+func TestConsoleFormatter(t *testing.T) {
+	t.Run("Plain", func(t *testing.T) {
+		formatter := NewConsoleFormatter(false)
+		formatter.BarWidth = 10
+		data, err := formatter.Format(nil, newConsoleEntry())
+		assert.NoError(t, err)
+
+		expected := strings.Join([]string{
+			"03:04:05 POST /api/users error 2.00s trace=trace-1",
+			"  user_id=42",
+			"  error: db failed",
+			"  ├─ handler            [" + strings.Repeat("█", 10) + "] 2.00s",
+			"  └─ repository.GetUser [" + strings.Repeat("·", 5) + strings.Repeat("█", 2) + strings.Repeat("·", 3) + "] 500.0ms",
+			"       error: not found",
+			"",
+		}, "\n")
+		assert.Equal(t, expected, string(data))
+		assert.NotContains(t, string(data), "\x1b[", "Plain output should not contain escapes")
+	})
+
+	t.Run("Colored", func(t *testing.T) {
+		data, err := NewConsoleFormatter(true).Format(nil, newConsoleEntry())
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), colorRed+"error"+colorReset, "State should be coloured")
+		assert.Contains(t, string(data), colorRed+"error: not found"+colorReset, "Events should be coloured by level")
+	})
+
+	t.Run("Sub-second waterfall", func(t *testing.T) {
+		start := time.Date(2024, 1, 2, 3, 4, 5, 900*int(time.Millisecond), time.UTC)
+		entry := &core.Entry{StartTime: start, Duration: 0.4, State: "success"}
+		entry.Spans = slices.Grow(entry.Spans, 2)[:2]
+		entry.Spans[0].Function, entry.Spans[0].StartTime, entry.Spans[0].Duration = "first", start, 0.1
+		entry.Spans[1].Function, entry.Spans[1].StartTime, entry.Spans[1].Duration = "second", start.Add(200*time.Millisecond), 0.1
+		logEntry := acquireLogEntry(entry)
+		defer releaseLogEntry(logEntry)
+		assert.Equal(t, "2024-01-02T03:04:05Z", logEntry.StartTime, "Encoded timestamps should stay RFC3339")
+
+		formatter := NewConsoleFormatter(false)
+		formatter.BarWidth = 8
+		data, err := formatter.Format(nil, logEntry)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "first  [██······]")
+		assert.Contains(t, string(data), "second [····██··]")
+	})
+
+	t.Run("Control characters", func(t *testing.T) {
+		data, err := NewConsoleFormatter(false).Format(nil, &LogEntry{
+			State:        "error",
+			OriginalPath: "/users\n\x1b[32m200 OK",
+			Metadata:     map[string]interface{}{"agent": "curl\u009b31m"},
+			Errors:       []string{"bad\r\nfake line"},
+			Spans: []*SpanEntry{{
+				Function: "handler\x1b[0m",
+				Event:    &EventEntry{Level: "info", Message: "done\n\x07"},
+			}},
+		})
+		assert.NoError(t, err)
+		out := string(data)
+		assert.NotContains(t, out, "\x1b", "Escape sequences should not reach the terminal")
+		assert.NotContains(t, out, "\u009b")
+		assert.NotContains(t, out, "\a")
+		assert.Equal(t, 5, strings.Count(out, "\n"), "Values should not add lines")
+		assert.Contains(t, out, `/users\n\x1b[32m200 OK`)
+		assert.Contains(t, out, `error: bad\r\nfake line`)
+		assert.Contains(t, out, `agent=curl\u009b31m`)
+		assert.Contains(t, out, `handler\x1b[0m [`)
+		assert.Contains(t, out, `info: done\n\a`)
+	})
+
+	t.Run("Multibyte names", func(t *testing.T) {
+		name := strings.Repeat("ฟังก์ชัน", 10)
+		formatter := NewConsoleFormatter(false)
+		data, err := formatter.Format(nil, &LogEntry{Spans: []*SpanEntry{{Function: name}}})
+		assert.NoError(t, err)
+		assert.True(t, utf8.Valid(data), "Names should be cut on a rune boundary")
+		assert.Contains(t, string(data), string([]rune(name)[:maxConsoleNameWidth])+" [")
+	})
+
+	t.Run("Durations", func(t *testing.T) {
+		assert.Equal(t, "1.50s", string(appendConsoleDuration(nil, 1.5)))
+		assert.Equal(t, "25.0ms", string(appendConsoleDuration(nil, 0.025)))
+		assert.Equal(t, "120µs", string(appendConsoleDuration(nil, 0.00012)))
+	})
+
+	t.Run("Terminal detection", func(t *testing.T) {
+		file, err := os.CreateTemp(t.TempDir(), "console")
+		assert.NoError(t, err)
+		defer file.Close()
+		assert.False(t, isTerminal(file), "Regular files are not terminals")
+		assert.False(t, isTerminal(&strings.Builder{}))
+
+		t.Setenv("NO_COLOR", "1")
+		assert.False(t, isTerminal(os.Stdout))
+	})
+}
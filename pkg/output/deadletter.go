@@ -0,0 +1,148 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+// DeadLetterConfig represents dead-letter output configuration
+type DeadLetterConfig struct {
+	Name    string            // name of the wrapped output, used when its error does not carry one
+	Writer  io.Writer         // receives one JSON DeadLetter per line, closed with the output if it is an io.Closer
+	Metrics *core.SelfMetrics // receives output.<name>.dead_lettered, optional
+}
+
+// DeadLetter represents a dead-lettered entry with the reason it failed
+type DeadLetter struct {
+	Output   string      `json:"output"`
+	Reason   string      `json:"reason"`
+	Attempts int         `json:"attempts"`
+	Time     time.Time   `json:"time"`
+	Entry    *core.Entry `json:"entry"` // the original entry, ready to be written again
+}
+
+// DeadLetterOutput wraps an output and writes entries it fails to write to a
+// JSON-lines dead-letter sink, with the output name, failure reason and
+// attempt count. Wrap a ResilientOutput so only entries that exhausted their
+// retries or failed permanently are dead-lettered; writes rejected by an open
+// circuit are returned as errors since they were never attempted.
+type DeadLetterOutput struct {
+	output Output
+	config DeadLetterConfig
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewDeadLetterOutput creates a dead-letter output
+func NewDeadLetterOutput(output Output, config DeadLetterConfig) *DeadLetterOutput {
+	if config.Name == "" {
+		config.Name = "output"
+	}
+	return &DeadLetterOutput{
+		output: output,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Write writes entries to the wrapped output. Failed entries are written to
+// the dead-letter sink and Write only fails if that fails too. After a
+// *PartialWriteError only the entries that were not written are failed.
+func (o *DeadLetterOutput) Write(entries []*core.Entry) error {
+	err := o.output.Write(entries)
+	if err == nil || o.config.Writer == nil || errors.Is(err, ErrCircuitOpen) {
+		return err
+	}
+	entries = unwritten(entries, err)
+
+	letter := DeadLetter{Output: o.config.Name, Reason: err.Error(), Attempts: 1, Time: o.now().UTC()}
+	var writeErr *WriteError
+	if errors.As(err, &writeErr) {
+		letter.Output, letter.Reason, letter.Attempts = writeErr.Output, writeErr.Err.Error(), writeErr.Attempts
+	}
+
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	for _, entry := range entries {
+		letter.Entry = entry
+		data, merr := json.Marshal(letter)
+		if merr != nil {
+			return errors.Join(err, fmt.Errorf("dead letter: %w", merr))
+		}
+		buf.bytes = append(append(buf.bytes, data...), '\n')
+	}
+
+	o.mu.Lock()
+	_, werr := o.config.Writer.Write(buf.bytes)
+	o.mu.Unlock()
+	if werr != nil {
+		return errors.Join(err, fmt.Errorf("dead letter: %w", werr))
+	}
+	o.config.Metrics.Add("output."+letter.Output+".dead_lettered", int64(len(entries)))
+	return nil
+}
+
+// Flush flushes the wrapped output and the dead-letter sink
+func (o *DeadLetterOutput) Flush() error {
+	err := o.output.Flush()
+	if o.config.Writer != nil {
+		o.mu.Lock()
+		err = errors.Join(err, flushWriter(o.config.Writer))
+		o.mu.Unlock()
+	}
+	return err
+}
+
+// Close closes the wrapped output, then flushes and closes the dead-letter sink
+func (o *DeadLetterOutput) Close() error {
+	err := o.output.Close()
+	if o.config.Writer != nil {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		err = errors.Join(err, flushWriter(o.config.Writer))
+		if c, ok := o.config.Writer.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	return err
+}
+
+// ReadDeadLetters reads the entries of a dead-letter sink so they can be
+// written to an output again
+func ReadDeadLetters(r io.Reader) ([]*core.Entry, error) {
+	letters, err := ReadDeadLetterRecords(r)
+	entries := make([]*core.Entry, len(letters))
+	for i, letter := range letters {
+		entries[i] = letter.Entry
+	}
+	return entries, err
+}
+
+// ReadDeadLetterRecords reads a dead-letter sink with the failure details
+// of each entry, so tooling can inspect them
+func ReadDeadLetterRecords(r io.Reader) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordBytes)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return letters, fmt.Errorf("dead letter line %d: %w", line, err)
+		}
+		if letter.Entry == nil {
+			return letters, fmt.Errorf("dead letter line %d: missing entry", line)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
@@ -0,0 +1,118 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestDeadLetterOutput(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Exhausted retries", func(t *testing.T) {
+		var buf bytes.Buffer
+		metrics := core.NewSelfMetrics()
+		target := newFlakyOutput(10, errors.New("timeout"))
+		output := NewDeadLetterOutput(
+			NewResilientOutput(target, RetryConfig{Name: "elasticsearch", InitialBackoff: time.Millisecond}),
+			DeadLetterConfig{Writer: &buf, Metrics: metrics},
+		)
+		output.now = func() time.Time { return now }
+
+		entries := newSpoolEntries(0, 2)
+		assert.NoError(t, output.Write(entries), "Dead-lettered write should succeed")
+		assert.Equal(t, int64(2), metrics.Get("output.elasticsearch.dead_lettered"))
+
+		letters, err := ReadDeadLetterRecords(bytes.NewReader(buf.Bytes()))
+		assert.NoError(t, err)
+		assert.Len(t, letters, 2)
+		for i, letter := range letters {
+			assert.Equal(t, "elasticsearch", letter.Output)
+			assert.Equal(t, "timeout", letter.Reason)
+			assert.Equal(t, 3, letter.Attempts)
+			assert.Equal(t, now, letter.Time)
+			assert.Equal(t, entries[i].TraceID, letter.Entry.TraceID, "Original entry should be kept")
+		}
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		var buf bytes.Buffer
+		target := &limitedOutput{TestOutput: NewTestOutput(), remaining: 1}
+		output := NewDeadLetterOutput(target, DeadLetterConfig{Writer: &buf})
+
+		assert.NoError(t, output.Write(newSpoolEntries(0, 3)))
+		letters, err := ReadDeadLetterRecords(bytes.NewReader(buf.Bytes()))
+		assert.NoError(t, err)
+		assert.Len(t, letters, 2, "Written entries should not be dead-lettered")
+		assert.Equal(t, "trace-1", letters[0].Entry.TraceID)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		var buf bytes.Buffer
+		output := NewDeadLetterOutput(&failingOutput{}, DeadLetterConfig{Name: "mongo", Writer: &buf})
+		entries := newSpoolEntries(0, 3)
+		entries[0].StartTime = time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+		assert.NoError(t, output.Write(entries))
+
+		replayed, err := ReadDeadLetters(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, traceIDs(entries), traceIDs(replayed))
+		assert.True(t, entries[0].StartTime.Equal(replayed[0].StartTime), "Timestamps should keep full precision")
+
+		target := NewTestOutput()
+		assert.NoError(t, target.Write(replayed), "Dead letters should be writable to an output")
+		assert.Len(t, target.Entries(), 3)
+	})
+
+	t.Run("Permanent failure", func(t *testing.T) {
+		var buf bytes.Buffer
+		target := newFlakyOutput(10, Permanent(errors.New("malformed entry")))
+		output := NewDeadLetterOutput(NewResilientOutput(target, RetryConfig{Name: "kafka"}), DeadLetterConfig{Writer: &buf})
+
+		assert.NoError(t, output.Write(newSpoolEntries(0, 1)))
+		letters, err := ReadDeadLetterRecords(&buf)
+		assert.NoError(t, err)
+		assert.Len(t, letters, 1)
+		assert.Equal(t, "malformed entry", letters[0].Reason)
+		assert.Equal(t, 1, letters[0].Attempts)
+	})
+
+	t.Run("Open circuit", func(t *testing.T) {
+		var buf bytes.Buffer
+		primary := NewResilientOutput(&failingOutput{}, RetryConfig{MaxAttempts: 1, FailureThreshold: 1, OpenTimeout: time.Hour})
+		output := NewDeadLetterOutput(primary, DeadLetterConfig{Writer: &buf})
+		assert.NoError(t, output.Write(newSpoolEntries(0, 1)))
+		buf.Reset()
+
+		assert.ErrorIs(t, output.Write(newSpoolEntries(1, 1)), ErrCircuitOpen, "Entries never attempted should not be dead-lettered")
+		assert.Zero(t, buf.Len())
+	})
+
+	t.Run("Sink failure", func(t *testing.T) {
+		output := NewDeadLetterOutput(&failingOutput{}, DeadLetterConfig{Writer: failingWriter{}})
+		assert.Error(t, output.Write(newSpoolEntries(0, 1)))
+	})
+
+	t.Run("Healthy output", func(t *testing.T) {
+		var buf bytes.Buffer
+		output := NewDeadLetterOutput(NewTestOutput(), DeadLetterConfig{Writer: &buf})
+		assert.NoError(t, output.Write(newSpoolEntries(0, 1)))
+		assert.Zero(t, buf.Len())
+	})
+
+	t.Run("Corrupt line", func(t *testing.T) {
+		_, err := ReadDeadLetters(strings.NewReader("{\"output\":\"x\"}\n"))
+		assert.Error(t, err)
+	})
+}
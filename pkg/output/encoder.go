@@ -0,0 +1,350 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The append encoders below produce the same bytes as encoding/json for
+// LogEntry, SpanEntry and EventEntry without reflection. Map values of
+// types not handled here fall back to json.Marshal.
+
+const hexDigits = "0123456789abcdef"
+
+// AppendLogEntry appends the JSON encoding of e to dst
+func AppendLogEntry(dst []byte, e *LogEntry) ([]byte, error) {
+	if e == nil {
+		return append(dst, "null"...), nil
+	}
+	var err error
+	dst = append(dst, '{')
+	if e.TraceID != "" {
+		dst = append(dst, `"trace_id":`...)
+		dst = appendString(dst, e.TraceID)
+		dst = append(dst, ',')
+	}
+	if e.RequestID != "" {
+		dst = append(dst, `"request_id":`...)
+		dst = appendString(dst, e.RequestID)
+		dst = append(dst, ',')
+	}
+	dst = append(dst, `"start_time":`...)
+	dst = appendString(dst, e.StartTime)
+	if e.EndTime != "" {
+		dst = append(dst, `,"end_time":`...)
+		dst = appendString(dst, e.EndTime)
+	}
+	if e.Duration != 0 {
+		dst = append(dst, `,"duration":`...)
+		if dst, err = appendFloat(dst, e.Duration, 64); err != nil {
+			return dst, err
+		}
+	}
+	dst = append(dst, `,"state":`...)
+	dst = appendString(dst, e.State)
+	if e.Method != "" {
+		dst = append(dst, `,"method":`...)
+		dst = appendString(dst, e.Method)
+	}
+	if e.OriginalPath != "" {
+		dst = append(dst, `,"original_path":`...)
+		dst = appendString(dst, e.OriginalPath)
+	}
+	if len(e.Metadata) > 0 {
+		dst = append(dst, `,"metadata":`...)
+		if dst, err = appendMap(dst, e.Metadata); err != nil {
+			return dst, err
+		}
+	}
+	if len(e.Errors) > 0 {
+		dst = append(dst, `,"errors":`...)
+		dst = appendStrings(dst, e.Errors)
+	}
+	if len(e.Spans) > 0 {
+		dst = append(dst, `,"spans":[`...)
+		for i, span := range e.Spans {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = AppendSpanEntry(dst, span); err != nil {
+				return dst, err
+			}
+		}
+		dst = append(dst, ']')
+	}
+	if e.Sampling != nil {
+		dst = append(dst, `,"sampling":{"sampled":`...)
+		dst = strconv.AppendBool(dst, e.Sampling.Sampled)
+		dst = append(dst, `,"reason":`...)
+		dst = appendString(dst, e.Sampling.Reason)
+		dst = append(dst, '}')
+	}
+	if e.Truncated {
+		dst = append(dst, `,"truncated":true`...)
+	}
+	return append(dst, '}'), nil
+}
+
+// AppendSpanEntry appends the JSON encoding of s to dst
+func AppendSpanEntry(dst []byte, s *SpanEntry) ([]byte, error) {
+	if s == nil {
+		return append(dst, "null"...), nil
+	}
+	var err error
+	dst = append(dst, `{"function":`...)
+	dst = appendString(dst, s.Function)
+	dst = append(dst, `,"start_time":`...)
+	dst = appendString(dst, s.StartTime)
+	if s.EndTime != "" {
+		dst = append(dst, `,"end_time":`...)
+		dst = appendString(dst, s.EndTime)
+	}
+	if s.Duration != 0 {
+		dst = append(dst, `,"duration":`...)
+		if dst, err = appendFloat(dst, s.Duration, 64); err != nil {
+			return dst, err
+		}
+	}
+	if len(s.Input) > 0 {
+		dst = append(dst, `,"input":`...)
+		if dst, err = appendMap(dst, s.Input); err != nil {
+			return dst, err
+		}
+	}
+	if len(s.Output) > 0 {
+		dst = append(dst, `,"output":`...)
+		if dst, err = appendMap(dst, s.Output); err != nil {
+			return dst, err
+		}
+	}
+	if s.Event != nil {
+		dst = append(dst, `,"event":`...)
+		dst = AppendEventEntry(dst, s.Event)
+	}
+	dst = append(dst, `,"span_id":`...)
+	dst = appendString(dst, s.SpanID)
+	return append(dst, '}'), nil
+}
+
+// AppendEventEntry appends the JSON encoding of e to dst
+func AppendEventEntry(dst []byte, e *EventEntry) []byte {
+	if e == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, `{"level":`...)
+	dst = appendString(dst, e.Level)
+	dst = append(dst, `,"message":`...)
+	dst = appendString(dst, e.Message)
+	return append(dst, '}')
+}
+
+// appendMap encodes a map with sorted keys like encoding/json
+func appendMap(dst []byte, m map[string]interface{}) ([]byte, error) {
+	if m == nil {
+		return append(dst, "null"...), nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var err error
+	dst = append(dst, '{')
+	for i, k := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, k)
+		dst = append(dst, ':')
+		if dst, err = appendValue(dst, m[k]); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+func appendValue(dst []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendString(dst, val), nil
+	case bool:
+		return strconv.AppendBool(dst, val), nil
+	case int:
+		return strconv.AppendInt(dst, int64(val), 10), nil
+	case int8:
+		return strconv.AppendInt(dst, int64(val), 10), nil
+	case int16:
+		return strconv.AppendInt(dst, int64(val), 10), nil
+	case int32:
+		return strconv.AppendInt(dst, int64(val), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, val, 10), nil
+	case uint:
+		return strconv.AppendUint(dst, uint64(val), 10), nil
+	case uint8:
+		return strconv.AppendUint(dst, uint64(val), 10), nil
+	case uint16:
+		return strconv.AppendUint(dst, uint64(val), 10), nil
+	case uint32:
+		return strconv.AppendUint(dst, uint64(val), 10), nil
+	case uint64:
+		return strconv.AppendUint(dst, val, 10), nil
+	case float32:
+		return appendFloat(dst, float64(val), 32)
+	case float64:
+		return appendFloat(dst, val, 64)
+	case []byte:
+		if val == nil {
+			return append(dst, "null"...), nil
+		}
+		n := len(dst) + 1
+		dst = append(dst, make([]byte, base64.StdEncoding.EncodedLen(len(val))+2)...)
+		dst[n-1] = '"'
+		base64.StdEncoding.Encode(dst[n:], val)
+		dst[len(dst)-1] = '"'
+		return dst, nil
+	case map[string]interface{}:
+		return appendMap(dst, val)
+	case map[string]string:
+		if val == nil {
+			return append(dst, "null"...), nil
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		dst = append(dst, '{')
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendString(dst, k)
+			dst = append(dst, ':')
+			dst = appendString(dst, val[k])
+		}
+		return append(dst, '}'), nil
+	case []interface{}:
+		if val == nil {
+			return append(dst, "null"...), nil
+		}
+		var err error
+		dst = append(dst, '[')
+		for i, item := range val {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendValue(dst, item); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, ']'), nil
+	case []string:
+		if val == nil {
+			return append(dst, "null"...), nil
+		}
+		return appendStrings(dst, val), nil
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, b...), nil
+	}
+}
+
+func appendStrings(dst []byte, values []string) []byte {
+	dst = append(dst, '[')
+	for i, s := range values {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendString(dst, s)
+	}
+	return append(dst, ']')
+}
+
+// appendFloat formats floats like encoding/json
+func appendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendString quotes s like encoding/json with HTML escaping enabled
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 break JavaScript parsers, encoding/json escapes them
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
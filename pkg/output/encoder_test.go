@@ -0,0 +1,133 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeJSON encodes v the way StdoutOutput did with encoding/json
+func encodeJSON(t testing.TB, v interface{}) []byte {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newEncoderEntry() *LogEntry {
+	return &LogEntry{
+		TraceID:      "trace-1",
+		RequestID:    "req-1",
+		StartTime:    "2024-01-02T03:04:05Z",
+		EndTime:      "2024-01-02T03:04:06Z",
+		Duration:     1.25,
+		State:        "error",
+		Method:       "POST",
+		OriginalPath: "/api/users?name=<script>&x=1",
+		Metadata: map[string]interface{}{
+			"string":  "quote \" backslash \\ newline \n tab \t ctrl \x01 \b \f html <>& \u0e44\u0e17\u0e22 \u2028 \u2029",
+			"int":     42,
+			"int64":   int64(-7),
+			"uint8":   uint8(200),
+			"float":   0.000000123,
+			"big":     1e21,
+			"float32": float32(0.1),
+			"bool":    true,
+			"nil":     nil,
+			"bytes":   []byte("hello world"),
+			"list":    []interface{}{"a", 1, 2.5, nil, map[string]interface{}{"z": 1, "a": 2}},
+			"strings": []string{"x", "y"},
+			"labels":  map[string]string{"b": "2", "a": "1"},
+			"time":    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+			"struct":  struct{ Name string }{Name: "n"},
+			"empty":   map[string]interface{}{},
+		},
+		Errors: []string{"database connection failed"},
+		Spans: []*SpanEntry{
+			{
+				Function:  "repository.GetUser",
+				StartTime: "2024-01-02T03:04:05Z",
+				EndTime:   "2024-01-02T03:04:06Z",
+				Duration:  0.5,
+				Input:     map[string]interface{}{"id": "42"},
+				Output:    map[string]interface{}{"found": false},
+				Event:     &EventEntry{Level: "error", Message: "not found"},
+				SpanID:    "1",
+			},
+			{Function: "handler", StartTime: "2024-01-02T03:04:05Z", SpanID: "2"},
+			nil,
+		},
+		Sampling:  &SamplingEntry{Sampled: true, Reason: "error"},
+		Truncated: true,
+	}
+}
+
+func TestAppendLogEntry(t *testing.T) {
+	t.Run("Matches encoding/json", func(t *testing.T) {
+		entries := []*LogEntry{
+			newEncoderEntry(),
+			{StartTime: "2024-01-02T03:04:05Z", State: "success"},
+			{},
+		}
+		for _, entry := range entries {
+			data, err := AppendLogEntry(nil, entry)
+			assert.NoError(t, err)
+			assert.Equal(t, string(encodeJSON(t, entry)), string(append(data, '\n')))
+		}
+	})
+
+	t.Run("Floats", func(t *testing.T) {
+		for _, f := range []float64{0, 1, -1, 0.1, 1e-6, 1e-7, 123456789.125, 1e20, 1e21, -1e-9, math.MaxFloat64} {
+			data, err := appendFloat(nil, f, 64)
+			assert.NoError(t, err)
+			expected, _ := json.Marshal(f)
+			assert.Equal(t, string(expected), string(data))
+		}
+
+		_, err := AppendLogEntry(nil, &LogEntry{Duration: math.NaN()})
+		assert.Error(t, err, "NaN should not be encodable")
+	})
+
+	t.Run("Invalid UTF-8", func(t *testing.T) {
+		assert.Equal(t, `"a\ufffdb"`, string(appendString(nil, "a\xffb")), "Invalid bytes should be replaced like encoding/json v1")
+	})
+
+	t.Run("Nil values", func(t *testing.T) {
+		data, err := AppendLogEntry(nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "null", string(data))
+		assert.Equal(t, "null", string(AppendEventEntry(nil, nil)))
+	})
+}
+
+func BenchmarkAppendLogEntry(b *testing.B) {
+	entry := newEncoderEntry()
+	var buf []byte
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = AppendLogEntry(buf[:0], entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodingJSON(b *testing.B) {
+	entry := newEncoderEntry()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.Encode(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
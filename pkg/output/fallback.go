@@ -0,0 +1,115 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+// Metadata keys marking entries written to a fallback output
+const (
+	DivertedKey       = "_diverted"
+	DivertedFromKey   = "_diverted_from"
+	DivertedReasonKey = "_diverted_reason"
+)
+
+// DivertReasonCircuitOpen is the diversion reason when the primary's circuit is open
+const DivertReasonCircuitOpen = "circuit_open"
+
+// FallbackConfig represents fallback output configuration
+type FallbackConfig struct {
+	Name      string            // name of the primary output, recorded in the marker and metric names
+	Fallbacks []Output          // tried in order when the primary fails, e.g. stderr then a local file
+	Metrics   *core.SelfMetrics // receives output.<name>.diverted and .fallback_failed, optional
+}
+
+// FallbackOutput writes entries to a primary output and diverts them to the
+// first fallback that accepts them when the primary fails or its circuit is
+// open. Each diverted entry is marked with the primary's name and the reason,
+// so fallbacks must be MetadataWriters, like WriterOutput and FileOutput,
+// optionally wrapped in a ResilientOutput.
+type FallbackOutput struct {
+	primary Output
+	config  FallbackConfig
+}
+
+// NewFallbackOutput creates a fallback output. It fails if a fallback
+// would drop the diversion marker.
+func NewFallbackOutput(primary Output, config FallbackConfig) (*FallbackOutput, error) {
+	if config.Name == "" {
+		config.Name = "primary"
+	}
+	for i, fallback := range config.Fallbacks {
+		if !carriesMetadata(fallback) {
+			return nil, fmt.Errorf("fallback output: fallback %d (%T) cannot mark diverted entries, it must implement MetadataWriter", i, fallback)
+		}
+	}
+	return &FallbackOutput{
+		primary: primary,
+		config:  config,
+	}, nil
+}
+
+// Write writes entries to the primary output, or diverts them to a fallback.
+// After a *PartialWriteError only the entries the primary did not write
+// are diverted.
+func (o *FallbackOutput) Write(entries []*core.Entry) error {
+	err := o.primary.Write(entries)
+	if err == nil || len(o.config.Fallbacks) == 0 {
+		return err
+	}
+	entries = unwritten(entries, err)
+
+	metadata := map[string]interface{}{
+		DivertedKey:       true,
+		DivertedFromKey:   o.config.Name,
+		DivertedReasonKey: divertReason(err),
+	}
+	errs := []error{err}
+	for _, fallback := range o.config.Fallbacks {
+		ferr := writeWithMetadata(fallback, entries, metadata)
+		if ferr == nil {
+			o.config.Metrics.Add("output."+o.config.Name+".diverted", int64(len(entries)))
+			return nil
+		}
+		errs = append(errs, ferr)
+	}
+	o.config.Metrics.Add("output."+o.config.Name+".fallback_failed", int64(len(entries)))
+	return errors.Join(errs...)
+}
+
+// Flush flushes the primary and fallback outputs
+func (o *FallbackOutput) Flush() error {
+	errs := []error{o.primary.Flush()}
+	for _, fallback := range o.config.Fallbacks {
+		errs = append(errs, fallback.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the primary and fallback outputs
+func (o *FallbackOutput) Close() error {
+	errs := []error{o.primary.Close()}
+	for _, fallback := range o.config.Fallbacks {
+		errs = append(errs, fallback.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// carriesMetadata reports whether metadata written to output reaches its sink
+func carriesMetadata(output Output) bool {
+	if r, ok := output.(*ResilientOutput); ok {
+		return carriesMetadata(r.output)
+	}
+	_, ok := output.(MetadataWriter)
+	return ok
+}
+
+// divertReason describes why the primary output failed
+func divertReason(err error) string {
+	if errors.Is(err, ErrCircuitOpen) {
+		return DivertReasonCircuitOpen
+	}
+	return err.Error()
+}
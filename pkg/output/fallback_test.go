@@ -0,0 +1,115 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func (o *failingOutput) WriteWithMetadata(entries []*core.Entry, metadata map[string]interface{}) error {
+	return o.Write(entries)
+}
+
+func newTestFallback(t *testing.T, primary Output, config FallbackConfig) *FallbackOutput {
+	output, err := NewFallbackOutput(primary, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestFallbackOutput(t *testing.T) {
+	t.Run("Healthy primary", func(t *testing.T) {
+		var buf bytes.Buffer
+		primary := NewTestOutput()
+		output := newTestFallback(t, primary, FallbackConfig{Fallbacks: []Output{NewWriterOutput(WriterConfig{Writer: &buf})}})
+
+		assert.NoError(t, output.Write(newQueueEntries(2)))
+		assert.Len(t, primary.Entries(), 2)
+		assert.Zero(t, buf.Len())
+	})
+
+	t.Run("Diverted entries are marked", func(t *testing.T) {
+		var buf bytes.Buffer
+		metrics := core.NewSelfMetrics()
+		output := newTestFallback(t, &failingOutput{}, FallbackConfig{
+			Name:      "elasticsearch",
+			Fallbacks: []Output{NewWriterOutput(WriterConfig{Writer: &buf})},
+			Metrics:   metrics,
+		})
+
+		assert.NoError(t, output.Write(newQueueEntries(1)), "Diverted write should succeed")
+		var logged LogEntry
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &logged))
+		assert.Equal(t, true, logged.Metadata[DivertedKey])
+		assert.Equal(t, "elasticsearch", logged.Metadata[DivertedFromKey])
+		assert.Equal(t, "sink unavailable", logged.Metadata[DivertedReasonKey])
+		assert.Equal(t, int64(1), metrics.Get("output.elasticsearch.diverted"))
+	})
+
+	t.Run("Partial write", func(t *testing.T) {
+		var buf bytes.Buffer
+		primary := &partialOutput{TestOutput: NewTestOutput()}
+		output := newTestFallback(t, primary, FallbackConfig{Fallbacks: []Output{NewWriterOutput(WriterConfig{Writer: &buf})}})
+
+		assert.NoError(t, output.Write(newSpoolEntries(0, 3)))
+		assert.Len(t, primary.Entries(), 1)
+		assert.Equal(t, 2, strings.Count(buf.String(), "\n"), "Only unwritten entries should be diverted")
+		assert.NotContains(t, buf.String(), `"trace-0"`)
+	})
+
+	t.Run("Open circuit", func(t *testing.T) {
+		var buf bytes.Buffer
+		primary := NewResilientOutput(&failingOutput{}, RetryConfig{MaxAttempts: 1, FailureThreshold: 1, OpenTimeout: time.Hour})
+		primary.Write(newQueueEntries(1))
+		output := newTestFallback(t, primary, FallbackConfig{
+			Fallbacks: []Output{NewWriterOutput(WriterConfig{Writer: &buf})},
+		})
+
+		assert.NoError(t, output.Write(newQueueEntries(1)))
+		assert.Contains(t, buf.String(), `"_diverted_reason":"circuit_open"`)
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		var buf bytes.Buffer
+		last := NewResilientOutput(NewWriterOutput(WriterConfig{Writer: &buf}), RetryConfig{MaxAttempts: 1})
+		metrics := core.NewSelfMetrics()
+		output := newTestFallback(t, &failingOutput{}, FallbackConfig{
+			Fallbacks: []Output{&failingOutput{}, last},
+			Metrics:   metrics,
+		})
+		assert.NoError(t, output.Write(newQueueEntries(2)))
+		assert.Equal(t, 2, strings.Count(buf.String(), `"_diverted":true`), "Next fallback should be tried and mark entries")
+
+		output = newTestFallback(t, &failingOutput{}, FallbackConfig{
+			Fallbacks: []Output{&failingOutput{}},
+			Metrics:   metrics,
+		})
+		err := output.Write(newQueueEntries(1))
+		assert.Error(t, err, "All outputs failing should return an error")
+		assert.Equal(t, int64(1), metrics.Get("output.primary.fallback_failed"))
+	})
+
+	t.Run("Fallbacks dropping the marker", func(t *testing.T) {
+		for _, fallback := range []Output{
+			NewTestOutput(),
+			NewQueuedOutput(NewWriterOutput(WriterConfig{}), QueueConfig{}),
+			NewResilientOutput(NewTestOutput(), RetryConfig{}),
+		} {
+			_, err := NewFallbackOutput(&failingOutput{}, FallbackConfig{Fallbacks: []Output{fallback}})
+			assert.Error(t, err, "%T should be rejected", fallback)
+			fallback.Close()
+		}
+	})
+
+	t.Run("Divert reason", func(t *testing.T) {
+		assert.Equal(t, DivertReasonCircuitOpen, divertReason(ErrCircuitOpen))
+		assert.Equal(t, "boom", divertReason(errors.New("boom")))
+	})
+}
@@ -0,0 +1,462 @@
+package output
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	megabyte         = 1024 * 1024
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+)
+
+// RotationConfig represents log file rotation configuration, zero disables a limit
+type RotationConfig struct {
+	MaxSize    int  // megabytes before the file is rotated
+	Daily      bool // rotate when the local date changes
+	MaxAge     int  // days to keep rotated files
+	MaxBackups int  // number of rotated files to keep
+	Compress   bool // gzip rotated files in the background
+}
+
+// FileConfig represents file output configuration
+type FileConfig struct {
+	Path         string
+	Rotation     *RotationConfig // optional, the file grows without limit when nil
+	Pretty       bool            // use indented JSON when Formatter is not set
+	Formatter    Formatter       // formats each entry, defaults to JSON
+	Redactor     *Redactor       // masks sensitive data before encoding, optional
+	Limiter      *Limiter        // enforces size limits after redaction, optional
+	IgnoreSIGHUP bool            // do not reopen the file on SIGHUP
+	OnError      func(err error) // called with errors from rotation, compression and cleanup
+}
+
+// FileOutput writes entries to a file with optional rotation.
+// The file is reopened on SIGHUP so external tools like logrotate can
+// move it, and Flush syncs it to disk.
+type FileOutput struct {
+	*WriterOutput
+	file    *rotatingFile
+	signals chan os.Signal
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewFileOutput creates a new file output and opens the file
+func NewFileOutput(config FileConfig) (*FileOutput, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("file output: path is required")
+	}
+	file := newRotatingFile(config)
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+
+	o := &FileOutput{
+		WriterOutput: NewWriterOutput(WriterConfig{
+			Writer:    file,
+			Pretty:    config.Pretty,
+			Formatter: config.Formatter,
+			Redactor:  config.Redactor,
+			Limiter:   config.Limiter,
+		}),
+		file: file,
+		done: make(chan struct{}),
+	}
+	if !config.IgnoreSIGHUP {
+		o.signals = make(chan os.Signal, 1)
+		signal.Notify(o.signals, syscall.SIGHUP)
+		o.wg.Add(1)
+		go o.handleSignals()
+	}
+	return o, nil
+}
+
+// Reopen closes and reopens the file at the configured path
+func (o *FileOutput) Reopen() error {
+	return o.file.reopen()
+}
+
+// Rotate rotates the file immediately
+func (o *FileOutput) Rotate() error {
+	return o.file.forceRotate()
+}
+
+// Close flushes and closes the file and waits for background compression.
+// Writes after Close fail with os.ErrClosed.
+func (o *FileOutput) Close() error {
+	var err error
+	o.once.Do(func() {
+		if o.signals != nil {
+			signal.Stop(o.signals)
+		}
+		close(o.done)
+		o.wg.Wait()
+		err = o.WriterOutput.Close()
+		if cerr := o.file.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+func (o *FileOutput) handleSignals() {
+	defer o.wg.Done()
+	for {
+		select {
+		case <-o.signals:
+			if err := o.file.reopen(); err != nil {
+				o.file.reportError(err)
+			}
+		case <-o.done:
+			return
+		}
+	}
+}
+
+// rotatingFile is an io.Writer over a file that rotates it by size and date.
+// Rotated files are compressed and cleaned up by a single background worker.
+type rotatingFile struct {
+	path     string
+	rotation RotationConfig
+	maxSize  int64
+	onError  func(err error)
+	now      func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	mill    chan struct{}
+	millWg  sync.WaitGroup
+	millEnd sync.Once
+}
+
+func newRotatingFile(config FileConfig) *rotatingFile {
+	f := &rotatingFile{
+		path:    config.Path,
+		onError: config.OnError,
+		now:     time.Now,
+		mill:    make(chan struct{}, 1),
+	}
+	if config.Rotation != nil {
+		f.rotation = *config.Rotation
+		f.maxSize = int64(f.rotation.MaxSize) * megabyte
+	}
+	f.millWg.Add(1)
+	go f.runMill()
+	return f
+}
+
+// Write writes p to the file, rotating it first when p would exceed the
+// size limit or the date has changed
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size == 0 {
+		f.opened = f.now()
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Flush syncs the file to disk
+func (f *rotatingFile) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file and stops the background worker after pending work.
+// Later writes fail with os.ErrClosed.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.millEnd.Do(func() {
+		close(f.mill)
+	})
+	f.millWg.Wait()
+	return err
+}
+
+func (f *rotatingFile) open() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.openLocked()
+}
+
+func (f *rotatingFile) reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.openLocked()
+}
+
+func (f *rotatingFile) forceRotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotateLocked()
+}
+
+func (f *rotatingFile) openLocked() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	if f.size > 0 {
+		// keep daily rotation correct across restarts
+		f.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) shouldRotate(n int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	if f.rotation.Daily && f.size > 0 {
+		y1, m1, d1 := f.opened.Date()
+		y2, m2, d2 := f.now().Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// rotateLocked renames the current file to a timestamped backup and opens a new one
+func (f *rotatingFile) rotateLocked() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	if _, err := os.Stat(f.path); err == nil {
+		if err := os.Rename(f.path, f.backupName(f.now())); err != nil {
+			return err
+		}
+	}
+	if err := f.openLocked(); err != nil {
+		return err
+	}
+
+	select {
+	case f.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupName returns a free backup path such as app-2024-01-02T03-04-05.000.log
+func (f *rotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	base := prefix + t.Format(backupTimeFormat)
+	name := filepath.Join(dir, base+ext)
+	for i := 1; fileExists(name) || fileExists(name+compressSuffix); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
+	}
+	return name
+}
+
+func (f *rotatingFile) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(f.path)
+	name := filepath.Base(f.path)
+	ext = filepath.Ext(name)
+	return dir, strings.TrimSuffix(name, ext) + "-", ext
+}
+
+func (f *rotatingFile) runMill() {
+	defer f.millWg.Done()
+	for range f.mill {
+		if err := f.millOnce(); err != nil {
+			f.reportError(err)
+		}
+	}
+}
+
+// millOnce compresses rotated files and removes those over the retention limits
+func (f *rotatingFile) millOnce() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var remove []backupFile
+	if f.rotation.MaxBackups > 0 && len(backups) > f.rotation.MaxBackups {
+		remove = append(remove, backups[f.rotation.MaxBackups:]...)
+		backups = backups[:f.rotation.MaxBackups]
+	}
+	if f.rotation.MaxAge > 0 {
+		cutoff := f.now().Add(-time.Duration(f.rotation.MaxAge) * 24 * time.Hour)
+		keep := backups[:0]
+		for _, b := range backups {
+			if b.time.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				keep = append(keep, b)
+			}
+		}
+		backups = keep
+	}
+
+	var errs []error
+	for _, b := range remove {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	if f.rotation.Compress {
+		for _, b := range backups {
+			if !strings.HasSuffix(b.path, compressSuffix) {
+				if err := compressFile(b.path); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("file output: %v", errs)
+	}
+	return nil
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+// backups returns rotated files, newest first
+func (f *rotatingFile) backups() ([]backupFile, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		stamp := strings.TrimPrefix(strings.TrimSuffix(name, compressSuffix), prefix)
+		stamp = strings.TrimSuffix(stamp, ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), time: t})
+	}
+	slices.SortFunc(backups, func(a, b backupFile) int {
+		if c := b.time.Compare(a.time); c != 0 {
+			return c
+		}
+		return strings.Compare(b.path, a.path)
+	})
+	return backups, nil
+}
+
+func (f *rotatingFile) reportError(err error) {
+	if f.onError != nil {
+		f.onError(err)
+	}
+}
+
+// compressFile gzips path to path.gz and removes the original
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+compressSuffix); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
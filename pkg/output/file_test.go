@@ -0,0 +1,204 @@
+package output
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFileOutput creates a file output whose file rotates every maxSize bytes
+func newTestFileOutput(t *testing.T, rotation *RotationConfig, maxSize int64) (*FileOutput, string) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	output, err := NewFileOutput(FileConfig{
+		Path:     path,
+		Rotation: rotation,
+		OnError:  func(err error) { t.Error(err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output.file.maxSize = maxSize
+	t.Cleanup(func() { output.Close() })
+	return output, path
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFileOutput(t *testing.T) {
+	entries := []*core.Entry{newBenchEntry()}
+	line, _ := NewJSONFormatter().Format(nil, acquireLogEntry(newBenchEntry()))
+
+	t.Run("Write and flush", func(t *testing.T) {
+		output, path := newTestFileOutput(t, nil, 0)
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Flush())
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(line), string(data))
+	})
+
+	t.Run("Size rotation and backups", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{MaxBackups: 2}, int64(len(line))*2)
+		clock := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+		output.file.now = func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		}
+
+		for i := 0; i < 8; i++ {
+			assert.NoError(t, output.Write(entries))
+		}
+		assert.NoError(t, output.Close())
+
+		names := listDir(t, filepath.Dir(path))
+		assert.Len(t, names, 3, "Current file and two backups should remain: %v", names)
+		assert.Contains(t, names, "app.log")
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+			assert.NoError(t, err)
+			assert.Equal(t, 2, strings.Count(string(data), "\n"), "Each file should hold two entries")
+		}
+	})
+
+	t.Run("Daily rotation", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{Daily: true}, 0)
+		day := time.Date(2024, 1, 2, 23, 59, 0, 0, time.Local)
+		output.file.now = func() time.Time { return day }
+
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Write(entries))
+		day = day.Add(2 * time.Minute)
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Close())
+
+		names := listDir(t, filepath.Dir(path))
+		assert.ElementsMatch(t, []string{"app.log", "app-2024-01-03T00-01-00.000.log"}, names)
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, 1, strings.Count(string(data), "\n"), "New day should start a new file")
+	})
+
+	t.Run("Max age", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{MaxAge: 7}, 0)
+		dir := filepath.Dir(path)
+		old := filepath.Join(dir, "app-2020-01-01T00-00-00.000.log")
+		assert.NoError(t, os.WriteFile(old, []byte("old\n"), 0644))
+		unrelated := filepath.Join(dir, "other.log")
+		assert.NoError(t, os.WriteFile(unrelated, []byte("keep\n"), 0644))
+
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Rotate())
+		assert.NoError(t, output.Close())
+
+		names := listDir(t, dir)
+		assert.NotContains(t, names, filepath.Base(old), "Expired backup should be removed")
+		assert.Contains(t, names, "other.log", "Unrelated files should be kept")
+		assert.Len(t, names, 3)
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{Compress: true}, 0)
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Rotate())
+		assert.NoError(t, output.Close())
+
+		var compressed string
+		for _, name := range listDir(t, filepath.Dir(path)) {
+			if strings.HasSuffix(name, ".gz") {
+				compressed = filepath.Join(filepath.Dir(path), name)
+			}
+			assert.False(t, strings.HasSuffix(name, ".tmp"))
+		}
+		assert.NotEmpty(t, compressed, "Rotated file should be compressed")
+
+		file, err := os.Open(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(gz)
+		assert.NoError(t, err)
+		assert.Equal(t, string(line), string(data))
+	})
+
+	t.Run("Reopen on SIGHUP", func(t *testing.T) {
+		output, path := newTestFileOutput(t, nil, 0)
+		assert.NoError(t, output.Write(entries))
+
+		// logrotate moves the file away and signals the process
+		moved := path + ".1"
+		assert.NoError(t, os.Rename(path, moved))
+		output.signals <- syscall.SIGHUP
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, time.Second, 10*time.Millisecond, "File should be recreated")
+
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Flush())
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, string(line), string(data))
+		data, _ = os.ReadFile(moved)
+		assert.Equal(t, string(line), string(data))
+	})
+
+	t.Run("Write after close", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{}, int64(len(line)))
+		assert.NoError(t, output.Write(entries))
+		assert.NoError(t, output.Close())
+
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, output.Write(entries), os.ErrClosed, "Writes after close should fail without rotating")
+		}
+		assert.ErrorIs(t, output.Flush(), os.ErrClosed)
+		assert.ErrorIs(t, output.Rotate(), os.ErrClosed)
+		assert.NoError(t, output.Close(), "Close should be idempotent")
+		assert.Equal(t, []string{"app.log"}, listDir(t, filepath.Dir(path)))
+	})
+
+	t.Run("Concurrent writes", func(t *testing.T) {
+		output, path := newTestFileOutput(t, &RotationConfig{}, int64(len(line))*10)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					output.Write(entries)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.NoError(t, output.Close())
+
+		total := 0
+		for _, name := range listDir(t, filepath.Dir(path)) {
+			data, _ := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+			total += strings.Count(string(data), "\n")
+		}
+		assert.Equal(t, 100, total)
+	})
+}
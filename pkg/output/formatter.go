@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Formatter formats a single log entry into bytes
+type Formatter interface {
+	// Format appends the formatted entry, followed by a newline, to dst
+	Format(dst []byte, entry *LogEntry) ([]byte, error)
+}
+
+// FormatterFunc is a function implementing Formatter
+type FormatterFunc func(dst []byte, entry *LogEntry) ([]byte, error)
+
+// Format calls f(dst, entry)
+func (f FormatterFunc) Format(dst []byte, entry *LogEntry) ([]byte, error) {
+	return f(dst, entry)
+}
+
+// JSONFormatter formats entries as one JSON object per line
+type JSONFormatter struct{}
+
+// NewJSONFormatter creates a new JSON formatter
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+// Format appends the JSON encoding of entry to dst
+func (f *JSONFormatter) Format(dst []byte, entry *LogEntry) ([]byte, error) {
+	dst, err := AppendLogEntry(dst, entry)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, '\n'), nil
+}
+
+// PrettyJSONFormatter formats entries as indented JSON
+type PrettyJSONFormatter struct {
+	Indent string // defaults to two spaces
+}
+
+// NewPrettyJSONFormatter creates a new pretty JSON formatter
+func NewPrettyJSONFormatter() *PrettyJSONFormatter {
+	return &PrettyJSONFormatter{Indent: "  "}
+}
+
+// Format appends the indented JSON encoding of entry to dst
+func (f *PrettyJSONFormatter) Format(dst []byte, entry *LogEntry) ([]byte, error) {
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	var err error
+	if buf.bytes, err = AppendLogEntry(buf.bytes, entry); err != nil {
+		return dst, err
+	}
+
+	indent := f.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	out := bytes.NewBuffer(dst)
+	if err := json.Indent(out, buf.bytes, "", indent); err != nil {
+		return dst, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// defaultFormatter returns formatter, or a JSON formatter matching pretty when it is nil
+func defaultFormatter(formatter Formatter, pretty bool) Formatter {
+	if formatter != nil {
+		return formatter
+	}
+	if pretty {
+		return NewPrettyJSONFormatter()
+	}
+	return NewJSONFormatter()
+}
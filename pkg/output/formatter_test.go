@@ -0,0 +1,49 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatter(t *testing.T) {
+	entry := newEncoderEntry()
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := NewJSONFormatter().Format([]byte("prefix "), entry)
+		assert.NoError(t, err)
+		assert.Equal(t, "prefix "+string(encodeJSON(t, entry)), string(data), "Format should append to dst")
+	})
+
+	t.Run("Pretty JSON", func(t *testing.T) {
+		data, err := NewPrettyJSONFormatter().Format(nil, entry)
+		assert.NoError(t, err)
+		expected, _ := json.MarshalIndent(entry, "", "  ")
+		assert.Equal(t, string(expected)+"\n", string(data))
+
+		data, err = (&PrettyJSONFormatter{Indent: "\t"}).Format(nil, entry)
+		assert.NoError(t, err)
+		expected, _ = json.MarshalIndent(entry, "", "\t")
+		assert.Equal(t, string(expected)+"\n", string(data))
+	})
+
+	t.Run("Stdout formatter", func(t *testing.T) {
+		assert.IsType(t, &JSONFormatter{}, defaultFormatter(nil, false))
+		assert.IsType(t, &PrettyJSONFormatter{}, defaultFormatter(nil, true), "Pretty should select indented JSON")
+
+		var buf bytes.Buffer
+		output := NewWriterOutput(WriterConfig{
+			Writer: &buf,
+			Pretty: true,
+			Formatter: FormatterFunc(func(dst []byte, entry *LogEntry) ([]byte, error) {
+				return append(dst, entry.TraceID+"\n"...), nil
+			}),
+		})
+
+		assert.NoError(t, output.Write([]*core.Entry{newBenchEntry()}))
+		assert.Equal(t, "trace-1\n", buf.String(), "Formatter should take precedence over Pretty")
+	})
+}
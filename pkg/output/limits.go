@@ -0,0 +1,297 @@
+package output
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// Metadata keys recording what the limiter removed
+const (
+	TruncatedFieldsKey = "_truncated_fields"
+	TruncatedSpansKey  = "_truncated_spans"
+	TruncatedErrorsKey = "_truncated_errors"
+)
+
+// minFieldLength and minFields are the field length and map size the
+// limiter falls back to when an entry is still over MaxEntryBytes
+const (
+	minFieldLength = 64
+	minFields      = 16
+)
+
+// LimitConfig represents size limits for log entries, zero disables a limit
+type LimitConfig struct {
+	MaxMessageLength int // bytes per event message and error
+	MaxFieldLength   int // bytes per string value in metadata and span input/output
+	MaxFields        int // fields per map
+	MaxSpans         int // spans per entry
+	MaxEntryBytes    int // total encoded size of an entry, see Limiter.Apply
+}
+
+// Limiter enforces size limits on log entries.
+// Truncated values are marked and the entry's Truncated flag is set.
+type Limiter struct {
+	config LimitConfig
+}
+
+// NewLimiter creates a new limiter
+func NewLimiter(config LimitConfig) *Limiter {
+	return &Limiter{config: config}
+}
+
+// Apply enforces the limits on entry and reports whether anything was truncated.
+// Span maps are copied before truncation so the source entry is never modified.
+// Entries are shrunk until they encode within MaxEntryBytes, down to their
+// first error and fields of minFieldLength bytes; a MaxEntryBytes smaller
+// than such an entry leaves it over the limit, as small as it can be made.
+func (l *Limiter) Apply(entry *LogEntry) bool {
+	truncated := l.applyFields(entry, l.config.MaxFields, l.config.MaxFieldLength)
+
+	if l.config.MaxMessageLength > 0 && truncateMessages(entry, l.config.MaxMessageLength) {
+		truncated = true
+	}
+
+	if l.config.MaxSpans > 0 && len(entry.Spans) > l.config.MaxSpans {
+		l.dropSpans(entry, len(entry.Spans)-l.config.MaxSpans)
+		truncated = true
+	}
+
+	if l.config.MaxEntryBytes > 0 && l.enforceEntryBytes(entry) {
+		truncated = true
+	}
+
+	if truncated {
+		entry.Truncated = true
+	}
+	return truncated
+}
+
+// applyFields truncates maps and their string values
+func (l *Limiter) applyFields(entry *LogEntry, maxFields, maxLength int) bool {
+	if maxLength <= 0 && maxFields <= 0 {
+		return false
+	}
+	truncated := false
+	var ok bool
+	if entry.Metadata, ok = limitMap(entry.Metadata, maxFields, maxLength); ok {
+		truncated = true
+	}
+	for _, span := range entry.Spans {
+		if span == nil {
+			continue
+		}
+		if span.Input, ok = limitMap(span.Input, maxFields, maxLength); ok {
+			truncated = true
+		}
+		if span.Output, ok = limitMap(span.Output, maxFields, maxLength); ok {
+			truncated = true
+		}
+	}
+	return truncated
+}
+
+// dropSpans removes the last n spans and records how many were dropped
+func (l *Limiter) dropSpans(entry *LogEntry, n int) {
+	keep := len(entry.Spans) - n
+	clear(entry.Spans[keep:])
+	entry.Spans = entry.Spans[:keep]
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]interface{})
+	}
+	dropped, _ := entry.Metadata[TruncatedSpansKey].(int)
+	entry.Metadata[TruncatedSpansKey] = dropped + n
+}
+
+// dropErrors removes the last n errors and records how many were dropped
+func (l *Limiter) dropErrors(entry *LogEntry, n int) {
+	entry.Errors = entry.Errors[:len(entry.Errors)-n]
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]interface{})
+	}
+	dropped, _ := entry.Metadata[TruncatedErrorsKey].(int)
+	entry.Metadata[TruncatedErrorsKey] = dropped + n
+}
+
+// enforceEntryBytes shrinks the entry until it encodes within MaxEntryBytes:
+// first by dropping spans, then by shortening fields and maps, then by
+// removing metadata and span payloads, finally by shortening every remaining
+// string, dropping the last span and all errors but the first. An entry that
+// fails to encode never fits.
+func (l *Limiter) enforceEntryBytes(entry *LogEntry) bool {
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	fits := func() bool {
+		var err error
+		buf.bytes, err = AppendLogEntry(buf.bytes[:0], entry)
+		return err == nil && len(buf.bytes) <= l.config.MaxEntryBytes
+	}
+
+	if fits() {
+		return false
+	}
+	for len(entry.Spans) > 1 && !fits() {
+		l.dropSpans(entry, 1)
+	}
+	if fits() {
+		return true
+	}
+	maxFields := l.config.MaxFields
+	if maxFields <= 0 || maxFields > minFields {
+		maxFields = minFields
+	}
+	l.applyFields(entry, maxFields, minFieldLength)
+	if fits() {
+		return true
+	}
+
+	dropped, _ := entry.Metadata[TruncatedSpansKey].(int)
+	entry.Metadata = map[string]interface{}{
+		TruncatedFieldsKey: "metadata and span payloads removed",
+	}
+	if dropped > 0 {
+		entry.Metadata[TruncatedSpansKey] = dropped
+	}
+	for _, span := range entry.Spans {
+		if span != nil {
+			span.Input, span.Output = nil, nil
+		}
+	}
+	if fits() {
+		return true
+	}
+
+	truncateMessages(entry, minFieldLength)
+	entry.TraceID, _ = truncateString(entry.TraceID, minFieldLength)
+	entry.RequestID, _ = truncateString(entry.RequestID, minFieldLength)
+	entry.State, _ = truncateString(entry.State, minFieldLength)
+	entry.Method, _ = truncateString(entry.Method, minFieldLength)
+	entry.OriginalPath, _ = truncateString(entry.OriginalPath, minFieldLength)
+	for _, span := range entry.Spans {
+		if span != nil {
+			span.Function, _ = truncateString(span.Function, minFieldLength)
+			span.SpanID, _ = truncateString(span.SpanID, minFieldLength)
+		}
+	}
+	if !fits() && len(entry.Spans) > 0 {
+		l.dropSpans(entry, len(entry.Spans))
+	}
+	if !fits() && len(entry.Errors) > 1 {
+		l.dropErrors(entry, len(entry.Errors)-1)
+	}
+	return true
+}
+
+// truncateMessages shortens error messages and span event messages
+func truncateMessages(entry *LogEntry, maxLength int) bool {
+	truncated := false
+	for i, msg := range entry.Errors {
+		if s, ok := truncateString(msg, maxLength); ok {
+			entry.Errors[i] = s
+			truncated = true
+		}
+	}
+	for _, span := range entry.Spans {
+		if span == nil || span.Event == nil {
+			continue
+		}
+		if s, ok := truncateString(span.Event.Message, maxLength); ok {
+			span.Event = &EventEntry{Level: span.Event.Level, Message: s}
+			truncated = true
+		}
+	}
+	return truncated
+}
+
+// limitMap returns m limited to maxFields fields with string values of at most
+// maxLength bytes. A copy is returned when anything changes.
+func limitMap(m map[string]interface{}, maxFields, maxLength int) (map[string]interface{}, bool) {
+	if m == nil {
+		return nil, false
+	}
+	result, truncated := m, false
+	copied := false
+	copyOnWrite := func() {
+		if !copied {
+			result = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				result[k] = v
+			}
+			copied = true
+		}
+	}
+
+	if maxFields > 0 && len(m) > maxFields {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		result = make(map[string]interface{}, maxFields+1)
+		for _, k := range keys[:maxFields] {
+			result[k] = m[k]
+		}
+		result[TruncatedFieldsKey] = len(keys) - maxFields
+		copied, truncated = true, true
+	}
+
+	if maxLength > 0 {
+		for k, v := range result {
+			if limited, ok := limitValue(v, maxFields, maxLength); ok {
+				copyOnWrite()
+				result[k] = limited
+				truncated = true
+			}
+		}
+	}
+	return result, truncated
+}
+
+func limitValue(v interface{}, maxFields, maxLength int) (interface{}, bool) {
+	switch val := v.(type) {
+	case string:
+		return truncateString(val, maxLength)
+	case []byte:
+		return truncateBytes(val, maxLength)
+	case map[string]interface{}:
+		return limitMap(val, maxFields, maxLength)
+	case []interface{}:
+		var result []interface{}
+		for i, item := range val {
+			if limited, ok := limitValue(item, maxFields, maxLength); ok {
+				if result == nil {
+					result = slices.Clone(val)
+				}
+				result[i] = limited
+			}
+		}
+		if result != nil {
+			return result, true
+		}
+	}
+	return v, false
+}
+
+// truncateString cuts s to at most max bytes on a rune boundary and
+// appends a marker with the number of bytes removed
+func truncateString(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…[truncated %d bytes]", s[:cut], len(s)-cut), true
+}
+
+// truncateBytes returns b base64 encoded, as the encoder writes it, cut to
+// at most max bytes with the same marker as truncateString
+func truncateBytes(b []byte, max int) (interface{}, bool) {
+	if max <= 0 || base64.StdEncoding.EncodedLen(len(b)) <= max {
+		return b, false
+	}
+	keep := max / 4 * 3
+	return fmt.Sprintf("%s…[truncated %d bytes]", base64.StdEncoding.EncodeToString(b[:keep]), len(b)-keep), true
+}
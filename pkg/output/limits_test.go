@@ -0,0 +1,157 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("Field and message length", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxFieldLength: 5, MaxMessageLength: 4})
+		input := map[string]interface{}{
+			"body":  "0123456789",
+			"short": "ok",
+			"list":  []interface{}{"abcdefgh"},
+			"raw":   []byte("0123456789"),
+		}
+		entry := &LogEntry{
+			Metadata: map[string]interface{}{"note": "ไทยไทย"},
+			Errors:   []string{"connection refused"},
+			Spans: []*SpanEntry{{
+				Input: input,
+				Event: &EventEntry{Level: "error", Message: "timeout"},
+			}},
+		}
+
+		assert.True(t, limiter.Apply(entry))
+		assert.True(t, entry.Truncated)
+		span := entry.Spans[0]
+		assert.Equal(t, "01234…[truncated 5 bytes]", span.Input["body"])
+		assert.Equal(t, "ok", span.Input["short"])
+		assert.Equal(t, "MDEy…[truncated 7 bytes]", span.Input["raw"], "Byte slices should carry the marker")
+		assert.Equal(t, []interface{}{"abcde…[truncated 3 bytes]"}, span.Input["list"])
+		assert.Equal(t, "ไ…[truncated 15 bytes]", entry.Metadata["note"], "Should cut on a rune boundary")
+		assert.Equal(t, "conn…[truncated 14 bytes]", entry.Errors[0])
+		assert.Equal(t, "time…[truncated 3 bytes]", span.Event.Message)
+		assert.Equal(t, "0123456789", input["body"], "Source map should not be modified")
+	})
+
+	t.Run("Field count and spans", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxFields: 2, MaxSpans: 1})
+		entry := &LogEntry{
+			Metadata: map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4},
+			Spans:    []*SpanEntry{{Function: "a"}, {Function: "b"}, {Function: "c"}},
+		}
+
+		assert.True(t, limiter.Apply(entry))
+		assert.Equal(t, 1, entry.Metadata["a"])
+		assert.Equal(t, 2, entry.Metadata["b"])
+		assert.NotContains(t, entry.Metadata, "c")
+		assert.Equal(t, 2, entry.Metadata[TruncatedFieldsKey])
+		assert.Len(t, entry.Spans, 1)
+		assert.Equal(t, 2, entry.Metadata[TruncatedSpansKey])
+	})
+
+	t.Run("Entry bytes", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxEntryBytes: 1024})
+		entry := &LogEntry{StartTime: "2024-01-02T03:04:05Z", State: "success"}
+		for i := 0; i < 20; i++ {
+			entry.Spans = append(entry.Spans, &SpanEntry{
+				Function: fmt.Sprintf("span_%d", i),
+				Input:    map[string]interface{}{"body": strings.Repeat("x", 100)},
+			})
+		}
+
+		assert.True(t, limiter.Apply(entry))
+		data, err := AppendLogEntry(nil, entry)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(data), 1024)
+		assert.Less(t, len(entry.Spans), 20, "Spans should be dropped")
+
+		entry = &LogEntry{
+			StartTime: "2024-01-02T03:04:05Z",
+			Metadata:  map[string]interface{}{"body": strings.Repeat("x", 4096)},
+		}
+		assert.True(t, limiter.Apply(entry))
+		data, _ = AppendLogEntry(nil, entry)
+		assert.LessOrEqual(t, len(data), 1024, "Fields should be shortened")
+		assert.Contains(t, string(data), `"truncated":true`)
+	})
+
+	t.Run("Entry bytes without message limit", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxEntryBytes: 512})
+		long := strings.Repeat("x", 4096)
+		entry := &LogEntry{
+			StartTime:    "2024-01-02T03:04:05Z",
+			State:        "error",
+			OriginalPath: "/" + long,
+			Errors:       []string{long},
+			Spans: []*SpanEntry{{
+				Function: "handler",
+				Event:    &EventEntry{Level: "error", Message: long},
+			}},
+		}
+
+		assert.True(t, limiter.Apply(entry))
+		data, err := AppendLogEntry(nil, entry)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(data), 512, "Messages and path should be shortened")
+	})
+
+	t.Run("Entry bytes with many errors and fields", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxEntryBytes: 512})
+		input := make(map[string]interface{}, 1000)
+		for i := 0; i < 1000; i++ {
+			input[fmt.Sprintf("key_%d", i)] = i
+		}
+		entry := &LogEntry{StartTime: "2024-01-02T03:04:05Z", State: "error", Spans: []*SpanEntry{{Function: "handler", Input: input}}}
+		for i := 0; i < 1000; i++ {
+			entry.Errors = append(entry.Errors, fmt.Sprintf("retry %d failed", i))
+		}
+
+		assert.True(t, limiter.Apply(entry))
+		data, err := AppendLogEntry(nil, entry)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(data), 512, "Errors and span fields should be capped")
+		assert.Equal(t, []string{"retry 0 failed"}, entry.Errors, "First error should be kept")
+		assert.Equal(t, 999, entry.Metadata[TruncatedErrorsKey])
+		assert.Len(t, input, 1000, "Source map should not be modified")
+	})
+
+	t.Run("Entry bytes with many span fields", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxEntryBytes: 1024})
+		input := make(map[string]interface{}, 200)
+		for i := 0; i < 200; i++ {
+			input[fmt.Sprintf("key_%03d", i)] = i
+		}
+		entry := &LogEntry{StartTime: "2024-01-02T03:04:05Z", State: "success", Spans: []*SpanEntry{{Function: "handler", Input: input}}}
+
+		assert.True(t, limiter.Apply(entry))
+		data, err := AppendLogEntry(nil, entry)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(data), 1024)
+		assert.Len(t, entry.Spans[0].Input, minFields+1, "Span input should be capped before it is removed")
+		assert.Equal(t, 200-minFields, entry.Spans[0].Input[TruncatedFieldsKey])
+	})
+
+	t.Run("Entry failing to encode", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxEntryBytes: 4096})
+		entry := &LogEntry{Metadata: map[string]interface{}{"ratio": math.NaN()}}
+
+		assert.True(t, limiter.Apply(entry), "An entry that cannot be encoded should not count as fitting")
+		_, err := AppendLogEntry(nil, entry)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Within limits", func(t *testing.T) {
+		limiter := NewLimiter(LimitConfig{MaxFieldLength: 100, MaxFields: 10, MaxSpans: 10, MaxEntryBytes: 4096})
+		entry := &LogEntry{Metadata: map[string]interface{}{"a": "b"}, Spans: []*SpanEntry{{Function: "a"}}}
+
+		assert.False(t, limiter.Apply(entry))
+		assert.False(t, entry.Truncated)
+	})
+}
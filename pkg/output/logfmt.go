@@ -0,0 +1,328 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter formats entries as logfmt key=value lines.
+// Nested spans and maps are flattened into dotted keys such as
+// spans.0.function and metadata.user.id; map keys are sorted so
+// lines are stable. Within a key segment '.', '%', '=', '"', spaces and
+// control characters are percent-encoded and an empty map key is written
+// as '%', so {"a.b":1} and {"a":{"b":1}} give different keys.
+type LogfmtFormatter struct{}
+
+// NewLogfmtFormatter creates a new logfmt formatter
+func NewLogfmtFormatter() *LogfmtFormatter {
+	return &LogfmtFormatter{}
+}
+
+// Format appends the logfmt encoding of entry to dst
+func (f *LogfmtFormatter) Format(dst []byte, entry *LogEntry) ([]byte, error) {
+	if entry == nil {
+		return append(dst, '\n'), nil
+	}
+	e := logfmtEncoder{dst: dst, start: len(dst)}
+	e.string("trace_id", entry.TraceID, true)
+	e.string("request_id", entry.RequestID, true)
+	e.string("start_time", entry.StartTime, false)
+	e.string("end_time", entry.EndTime, true)
+	if entry.Duration != 0 {
+		e.pair("duration")
+		e.dst = strconv.AppendFloat(e.dst, entry.Duration, 'g', -1, 64)
+	}
+	e.string("state", entry.State, false)
+	e.string("method", entry.Method, true)
+	e.string("original_path", entry.OriginalPath, true)
+	if len(entry.Metadata) > 0 {
+		e.push("metadata")
+		e.value(entry.Metadata)
+		e.pop()
+	}
+	if len(entry.Errors) > 0 {
+		e.push("errors")
+		e.value(entry.Errors)
+		e.pop()
+	}
+	for i, span := range entry.Spans {
+		if span == nil {
+			continue
+		}
+		e.push("spans")
+		e.index(i)
+		e.span(span)
+		e.pop()
+	}
+	if entry.Sampling != nil {
+		e.push("sampling")
+		e.pair("sampled")
+		e.dst = strconv.AppendBool(e.dst, entry.Sampling.Sampled)
+		e.string("reason", entry.Sampling.Reason, false)
+		e.pop()
+	}
+	if entry.Truncated {
+		e.pair("truncated")
+		e.dst = append(e.dst, "true"...)
+	}
+	return append(e.dst, '\n'), nil
+}
+
+// logfmtEncoder appends pairs to dst; key holds the dotted prefix of
+// the current nesting level
+type logfmtEncoder struct {
+	dst   []byte
+	start int
+	key   []byte
+	stack []int
+}
+
+// push appends name to the key prefix
+func (e *logfmtEncoder) push(name string) {
+	e.stack = append(e.stack, len(e.key))
+	if len(e.key) > 0 {
+		e.key = append(e.key, '.')
+	}
+	e.key = appendLogfmtKey(e.key, name)
+}
+
+// index appends a slice index to the key prefix
+func (e *logfmtEncoder) index(i int) {
+	e.key = append(e.key, '.')
+	e.key = strconv.AppendInt(e.key, int64(i), 10)
+}
+
+// pop restores the key prefix saved by the last push
+func (e *logfmtEncoder) pop() {
+	n := len(e.stack) - 1
+	e.key = e.key[:e.stack[n]]
+	e.stack = e.stack[:n]
+}
+
+// pair starts a pair for name under the current prefix
+func (e *logfmtEncoder) pair(name string) {
+	if len(e.dst) > e.start {
+		e.dst = append(e.dst, ' ')
+	}
+	e.dst = append(e.dst, e.key...)
+	if name != "" {
+		if len(e.key) > 0 {
+			e.dst = append(e.dst, '.')
+		}
+		e.dst = appendLogfmtKey(e.dst, name)
+	}
+	e.dst = append(e.dst, '=')
+}
+
+func (e *logfmtEncoder) string(name, value string, omitEmpty bool) {
+	if omitEmpty && value == "" {
+		return
+	}
+	e.pair(name)
+	e.dst = appendLogfmtString(e.dst, value)
+}
+
+func (e *logfmtEncoder) span(s *SpanEntry) {
+	e.string("function", s.Function, false)
+	e.string("start_time", s.StartTime, false)
+	e.string("end_time", s.EndTime, true)
+	if s.Duration != 0 {
+		e.pair("duration")
+		e.dst = strconv.AppendFloat(e.dst, s.Duration, 'g', -1, 64)
+	}
+	if len(s.Input) > 0 {
+		e.push("input")
+		e.value(s.Input)
+		e.pop()
+	}
+	if len(s.Output) > 0 {
+		e.push("output")
+		e.value(s.Output)
+		e.pop()
+	}
+	if s.Event != nil {
+		e.push("event")
+		e.string("level", s.Event.Level, false)
+		e.string("message", s.Event.Message, false)
+		e.pop()
+	}
+	e.string("span_id", s.SpanID, false)
+}
+
+// value writes v at the current prefix, flattening maps and slices
+func (e *logfmtEncoder) value(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			e.push(k)
+			e.value(val[k])
+			e.pop()
+		}
+	case map[string]string:
+		for _, k := range sortedKeys(val) {
+			e.push(k)
+			e.pair("")
+			e.dst = appendLogfmtString(e.dst, val[k])
+			e.pop()
+		}
+	case []interface{}:
+		for i, item := range val {
+			n := len(e.key)
+			e.index(i)
+			e.value(item)
+			e.key = e.key[:n]
+		}
+	case []string:
+		for i, item := range val {
+			n := len(e.key)
+			e.index(i)
+			e.pair("")
+			e.dst = appendLogfmtString(e.dst, item)
+			e.key = e.key[:n]
+		}
+	default:
+		e.pair("")
+		e.dst = appendLogfmtValue(e.dst, v)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// appendLogfmtValue appends a scalar value
+func appendLogfmtValue(dst []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return append(dst, "null"...)
+	case string:
+		return appendLogfmtString(dst, val)
+	case bool:
+		return strconv.AppendBool(dst, val)
+	case int:
+		return strconv.AppendInt(dst, int64(val), 10)
+	case int8:
+		return strconv.AppendInt(dst, int64(val), 10)
+	case int16:
+		return strconv.AppendInt(dst, int64(val), 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(val), 10)
+	case int64:
+		return strconv.AppendInt(dst, val, 10)
+	case uint:
+		return strconv.AppendUint(dst, uint64(val), 10)
+	case uint8:
+		return strconv.AppendUint(dst, uint64(val), 10)
+	case uint16:
+		return strconv.AppendUint(dst, uint64(val), 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(val), 10)
+	case uint64:
+		return strconv.AppendUint(dst, val, 10)
+	case float32:
+		return strconv.AppendFloat(dst, float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(dst, val, 'g', -1, 64)
+	case []byte:
+		return append(dst, base64.StdEncoding.EncodeToString(val)...)
+	case time.Time:
+		return val.AppendFormat(dst, time.RFC3339Nano)
+	case time.Duration:
+		return append(dst, val.String()...)
+	case error:
+		return appendLogfmtString(dst, val.Error())
+	case fmt.Stringer:
+		return appendLogfmtString(dst, val.String())
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return appendLogfmtString(dst, fmt.Sprint(val))
+		}
+		return appendLogfmtString(dst, string(b))
+	}
+}
+
+// appendLogfmtKey appends name as a key segment, percent-encoding the
+// separator, '%' and characters not allowed in keys. An empty name is
+// written as a lone '%', which no encoded name produces.
+func appendLogfmtKey(dst []byte, name string) []byte {
+	if name == "" {
+		return append(dst, '%')
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if b <= ' ' || b == '.' || b == '%' || b == '=' || b == '"' || b == 0x7f {
+			dst = append(dst, '%', hexDigits[b>>4], hexDigits[b&0xF])
+			continue
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// appendLogfmtString appends s, quoted when it is empty or contains
+// spaces, '=', '"', control characters or invalid UTF-8
+func appendLogfmtString(dst []byte, s string) []byte {
+	if !needsLogfmtQuote(s) {
+		return append(dst, s...)
+	}
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != 0x7f {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+func needsLogfmtQuote(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; b <= ' ' || b == '=' || b == '"' || b == '\\' || b == 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	format := func(entry *LogEntry) string {
+		data, err := NewLogfmtFormatter().Format(nil, entry)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(data), "\n"))
+		return strings.TrimSuffix(string(data), "\n")
+	}
+
+	t.Run("Flattened keys", func(t *testing.T) {
+		line := format(&LogEntry{
+			TraceID:   "trace-1",
+			StartTime: "2024-01-02T03:04:05Z",
+			Duration:  0.025,
+			State:     "error",
+			Method:    "GET",
+			Metadata: map[string]interface{}{
+				"user": map[string]interface{}{"id": 42, "roles": []interface{}{"admin", "dev"}},
+				"ok":   true,
+				"err":  errors.New("boom"),
+			},
+			Errors: []string{"db failed"},
+			Spans: []*SpanEntry{
+				{
+					Function:  "repo.Get",
+					StartTime: "2024-01-02T03:04:05Z",
+					Input:     map[string]interface{}{"id": "42"},
+					Event:     &EventEntry{Level: "error", Message: "not found"},
+					SpanID:    "1",
+				},
+				nil,
+				{Function: "handler", StartTime: "2024-01-02T03:04:05Z", SpanID: "3"},
+			},
+			Sampling:  &SamplingEntry{Sampled: true, Reason: "error"},
+			Truncated: true,
+		})
+
+		assert.Equal(t, "trace_id=trace-1 start_time=2024-01-02T03:04:05Z duration=0.025 state=error method=GET"+
+			" metadata.err=boom metadata.ok=true metadata.user.id=42 metadata.user.roles.0=admin metadata.user.roles.1=dev"+
+			` errors.0="db failed"`+
+			` spans.0.function=repo.Get spans.0.start_time=2024-01-02T03:04:05Z spans.0.input.id=42`+
+			` spans.0.event.level=error spans.0.event.message="not found" spans.0.span_id=1`+
+			` spans.2.function=handler spans.2.start_time=2024-01-02T03:04:05Z spans.2.span_id=3`+
+			` sampling.sampled=true sampling.reason=error truncated=true`, line)
+	})
+
+	t.Run("Quoting and escaping", func(t *testing.T) {
+		line := format(&LogEntry{
+			Metadata: map[string]interface{}{
+				"a b":     "x=y",
+				"quote":   `say "hi" \o/`,
+				"lines":   "one\ntwo\tthree\x01",
+				"empty":   "",
+				"nil":     nil,
+				"thai":    "\u0e44\u0e17\u0e22",
+				"invalid": "bad\xffbyte",
+			},
+		})
+
+		assert.Equal(t, `start_time="" state=""`+
+			` metadata.a%20b="x=y"`+
+			` metadata.empty=""`+
+			` metadata.invalid="bad`+"\ufffd"+`byte"`+
+			` metadata.lines="one\ntwo\tthree\u0001"`+
+			` metadata.nil=null`+
+			` metadata.quote="say \"hi\" \\o/"`+
+			" metadata.thai=\u0e44\u0e17\u0e22", line)
+	})
+
+	t.Run("Dotted map keys", func(t *testing.T) {
+		flat := format(&LogEntry{Metadata: map[string]interface{}{"a.b": 1, "100%": 2, "": 3}})
+		nested := format(&LogEntry{Metadata: map[string]interface{}{"a": map[string]interface{}{"b": 1}}})
+
+		assert.Equal(t, `start_time="" state="" metadata.%=3 metadata.100%25=2 metadata.a%2eb=1`, flat)
+		assert.Equal(t, `start_time="" state="" metadata.a.b=1`, nested)
+		assert.NotEqual(t, flat, nested, "Dots inside keys should not collide with nesting")
+	})
+
+	t.Run("Appends to dst", func(t *testing.T) {
+		data, err := NewLogfmtFormatter().Format([]byte("x=1\n"), &LogEntry{StartTime: "now", State: "ok"})
+		assert.NoError(t, err)
+		assert.Equal(t, "x=1\nstart_time=now state=ok\n", string(data))
+	})
+}
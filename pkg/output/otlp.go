@@ -0,0 +1,420 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318"
+	defaultOTLPTimeout  = 10 * time.Second
+	defaultOTLPBatch    = 512
+	defaultServiceName  = "unknown_service"
+	otlpScopeName       = "github.com/nat-prohmpiriya/goobserv"
+	maxOTLPErrorBody    = 512
+)
+
+// OTLPConfig represents OTLP/HTTP exporter configuration.
+// Requests use the OTLP JSON encoding.
+type OTLPConfig struct {
+	Endpoint     string                 // collector base URL, defaults to http://localhost:4318
+	Headers      map[string]string      // sent with every request, e.g. authentication
+	ServiceName  string                 // service.name resource attribute, defaults to unknown_service
+	Resource     map[string]interface{} // extra resource attributes
+	Timeout      time.Duration          // per request, defaults to 10s
+	MaxBatchSize int                    // spans or log records per request, defaults to 512
+	Uncompressed bool                   // send request bodies without gzip
+	Retry        RetryConfig            // attempts and backoff, circuit breaker fields are ignored
+	Client       *http.Client           // defaults to a client with Timeout
+}
+
+// otlpExporter posts OTLP JSON payloads to one collector path,
+// retrying throttled and unavailable responses
+type otlpExporter struct {
+	config OTLPConfig
+	url    string
+	client *http.Client
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newOTLPExporter(config OTLPConfig, path string) *otlpExporter {
+	if config.Endpoint == "" {
+		config.Endpoint = defaultOTLPEndpoint
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultOTLPTimeout
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaultOTLPBatch
+	}
+	if config.Retry.Name == "" {
+		config.Retry.Name = "otlp"
+	}
+	config.Retry.setBackoffDefaults()
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	return &otlpExporter{
+		config: config,
+		url:    strings.TrimSuffix(config.Endpoint, "/") + path,
+		client: client,
+		done:   make(chan struct{}),
+	}
+}
+
+// export sends payload, it returns a *WriteError once attempts are exhausted
+// and marks rejected payloads Permanent
+func (e *otlpExporter) export(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Permanent(err)
+	}
+	if !e.config.Uncompressed {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		retryAfter, err := e.post(body)
+		if err == nil {
+			return nil
+		}
+		if IsPermanent(err) || attempts >= e.config.Retry.MaxAttempts {
+			return &WriteError{Output: e.config.Retry.Name, Attempts: attempts, Err: err}
+		}
+		delay := e.config.Retry.backoff(attempts)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		if !e.wait(delay) {
+			return &WriteError{Output: e.config.Retry.Name, Attempts: attempts, Err: err}
+		}
+	}
+}
+
+// post sends one request. Per the OTLP spec 429, 502, 503 and 504 are
+// retryable and may carry Retry-After; other failures are permanent.
+func (e *otlpExporter) post(body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !e.config.Uncompressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxOTLPErrorBody))
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("otlp: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return e.retryAfter(resp.Header.Get("Retry-After"), time.Now()), err
+	default:
+		return 0, Permanent(err)
+	}
+}
+
+// exportEntries converts entries into items with appendItems and exports
+// them, at most MaxBatchSize per request built by request. It stops at the
+// first failed request; if earlier requests were accepted it returns a
+// *PartialWriteError holding the entries from the one whose items were not
+// all exported, so retrying them does not export the rest again.
+func exportEntries[T any](e *otlpExporter, entries []*core.Entry, appendItems func([]T, *core.Entry) []T, request func([]T) interface{}) error {
+	var items []T
+	starts := make([]int, len(entries))
+	for i, entry := range entries {
+		starts[i] = len(items)
+		items = appendItems(items, entry)
+	}
+	for sent := 0; sent < len(items); {
+		n := min(len(items)-sent, e.config.MaxBatchSize)
+		if err := e.export(request(items[sent : sent+n])); err != nil {
+			return otlpWriteError(entries, starts, sent, err)
+		}
+		sent += n
+	}
+	return nil
+}
+
+// otlpWriteError returns err for a Write of entries that stopped after
+// exporting sent items. starts holds the index of each entry's first item,
+// entries from the one owning the first unsent item are returned as remaining.
+func otlpWriteError(entries []*core.Entry, starts []int, sent int, err error) error {
+	if sent == 0 {
+		return err
+	}
+	owner := 0
+	for i, start := range starts {
+		if start <= sent {
+			owner = i
+		}
+	}
+	return &PartialWriteError{Remaining: entries[owner:], Err: err}
+}
+
+// retryAfter parses a Retry-After value given in seconds or as an HTTP date,
+// clamped to Retry.MaxBackoff so a collector cannot stall the exporter
+func (e *otlpExporter) retryAfter(value string, now time.Time) time.Duration {
+	var d time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	}
+	return max(0, min(d, e.config.Retry.MaxBackoff))
+}
+
+func (e *otlpExporter) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// Flush does nothing, every Write is exported immediately
+func (e *otlpExporter) Flush() error {
+	return nil
+}
+
+// Close interrupts pending retries
+func (e *otlpExporter) Close() error {
+	e.once.Do(func() {
+		close(e.done)
+	})
+	return nil
+}
+
+// resource returns the resource shared by all exported data
+func (e *otlpExporter) resource() otlpResource {
+	attributes := []otlpKeyValue{otlpAttribute("service.name", e.config.ServiceName)}
+	return otlpResource{Attributes: append(attributes, otlpAttributes("", e.config.Resource)...)}
+}
+
+// OTLP JSON payload types shared by the trace and log exporters
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *string           `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	BytesValue  *string           `json:"bytesValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue(value)}
+}
+
+// otlpAttributes converts a map to attributes sorted by key, with keys prefixed
+func otlpAttributes(prefix string, m map[string]interface{}) []otlpKeyValue {
+	if len(m) == 0 {
+		return nil
+	}
+	attributes := make([]otlpKeyValue, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		attributes = append(attributes, otlpAttribute(prefix+k, m[k]))
+	}
+	return attributes
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	switch val := v.(type) {
+	case nil:
+		return otlpAnyValue{}
+	case string:
+		return otlpAnyValue{StringValue: &val}
+	case bool:
+		return otlpAnyValue{BoolValue: &val}
+	case int:
+		return otlpInt(int64(val))
+	case int8:
+		return otlpInt(int64(val))
+	case int16:
+		return otlpInt(int64(val))
+	case int32:
+		return otlpInt(int64(val))
+	case int64:
+		return otlpInt(val)
+	case uint:
+		return otlpUint(uint64(val))
+	case uint8:
+		return otlpInt(int64(val))
+	case uint16:
+		return otlpInt(int64(val))
+	case uint32:
+		return otlpInt(int64(val))
+	case uint64:
+		return otlpUint(val)
+	case float32:
+		f := float64(val)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &val}
+	case []byte:
+		s := base64.StdEncoding.EncodeToString(val)
+		return otlpAnyValue{BytesValue: &s}
+	case []string:
+		values := make([]otlpAnyValue, len(val))
+		for i, item := range val {
+			values[i] = otlpValue(item)
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case []interface{}:
+		values := make([]otlpAnyValue, len(val))
+		for i, item := range val {
+			values[i] = otlpValue(item)
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: otlpAttributes("", val)}}
+	case time.Time:
+		s := val.Format(time.RFC3339Nano)
+		return otlpAnyValue{StringValue: &s}
+	case error:
+		s := val.Error()
+		return otlpAnyValue{StringValue: &s}
+	case fmt.Stringer:
+		s := val.String()
+		return otlpAnyValue{StringValue: &s}
+	default:
+		b, err := json.Marshal(val)
+		s := string(b)
+		if err != nil {
+			s = fmt.Sprint(val)
+		}
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpInt(i int64) otlpAnyValue {
+	s := strconv.FormatInt(i, 10)
+	return otlpAnyValue{IntValue: &s}
+}
+
+// otlpUint returns u as an int value, or as a string when it does not fit
+// in the int64 OTLP uses, so large values never wrap to negative numbers
+func otlpUint(u uint64) otlpAnyValue {
+	if u <= math.MaxInt64 {
+		return otlpInt(int64(u))
+	}
+	s := strconv.FormatUint(u, 10)
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpTime formats t as nanoseconds since the epoch, "0" when unset
+func otlpTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpTraceID returns id as 32 hex digits. IDs that already are 128-bit hex,
+// with or without UUID dashes, are kept; others are hashed so the mapping
+// is stable across services.
+func otlpTraceID(id string) string {
+	if hexID := strings.ToLower(strings.ReplaceAll(id, "-", "")); isHex(hexID, 32) {
+		return hexID
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// otlpEntryTraceID returns the OTLP trace ID of entry. Entries without a
+// trace ID get one derived from their request ID and start time, so the
+// trace and log exporters give the same request the same ID.
+func otlpEntryTraceID(entry *core.Entry) string {
+	if entry.TraceID != "" {
+		return otlpTraceID(entry.TraceID)
+	}
+	return otlpTraceID(entry.RequestID + "\x00" + strconv.FormatInt(entry.StartTime.UnixNano(), 10))
+}
+
+// otlpSpanID returns id as 16 hex digits, hashing it with the trace ID
+// unless it already is 64-bit hex
+func otlpSpanID(traceID, id string) string {
+	if hexID := strings.ToLower(id); isHex(hexID, 16) {
+		return hexID
+	}
+	sum := sha256.Sum256([]byte(traceID + "\x00" + id))
+	return hex.EncodeToString(sum[:8])
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" {
+		return false
+	}
+	return !slices.ContainsFunc([]byte(s), func(b byte) bool {
+		return !('0' <= b && b <= '9' || 'a' <= b && b <= 'f')
+	})
+}
@@ -0,0 +1,154 @@
+package output
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const otlpLogsPath = "/v1/logs"
+
+// OTLP severity numbers for event levels
+const (
+	otlpSeverityDebug = 5
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// OTLPLogOutput exports events to a collector's /v1/logs as OTLP log records
+// correlated with the spans exported by OTLPTraceOutput. Each span event
+// becomes a record with the span's trace and span IDs, its function and its
+// input and output as attributes. Entry errors become error records, and
+// entries without events a single record for the request.
+type OTLPLogOutput struct {
+	*otlpExporter
+}
+
+// NewOTLPLogOutput creates an OTLP logs exporter
+func NewOTLPLogOutput(config OTLPConfig) *OTLPLogOutput {
+	return &OTLPLogOutput{otlpExporter: newOTLPExporter(config, otlpLogsPath)}
+}
+
+// Write exports the records of entries, see exportEntries
+func (o *OTLPLogOutput) Write(entries []*core.Entry) error {
+	return exportEntries(o.otlpExporter, entries, appendOTLPLogRecords, func(records []otlpLogRecord) interface{} {
+		return otlpLogsRequest{
+			ResourceLogs: []otlpResourceLogs{{
+				Resource: o.resource(),
+				ScopeLogs: []otlpScopeLogs{{
+					Scope:      otlpScope{Name: otlpScopeName},
+					LogRecords: records,
+				}},
+			}},
+		}
+	})
+}
+
+// appendOTLPLogRecords converts the events and error of an entry into log records
+func appendOTLPLogRecords(records []otlpLogRecord, entry *core.Entry) []otlpLogRecord {
+	traceID := otlpEntryTraceID(entry)
+	rootID := otlpSpanID(traceID, "\x00root")
+	n := len(records)
+
+	for _, span := range entry.Spans {
+		if span.Event == nil {
+			continue
+		}
+		attributes := []otlpKeyValue{otlpAttribute("code.function", span.Function)}
+		attributes = append(attributes, otlpAttributes("input.", span.Input)...)
+		attributes = append(attributes, otlpAttributes("output.", span.Output)...)
+		records = append(records, newOTLPLogRecord(
+			endTime(span.StartTime, span.EndTime, span.Duration),
+			span.Event.Level, span.Event.Message,
+			traceID, otlpSpanID(traceID, span.SpanID), attributes,
+		))
+	}
+
+	var attributes []otlpKeyValue
+	if entry.Method != "" {
+		attributes = append(attributes, otlpAttribute("http.request.method", entry.Method))
+	}
+	if entry.OriginalPath != "" {
+		attributes = append(attributes, otlpAttribute("url.path", entry.OriginalPath))
+	}
+	if entry.RequestID != "" {
+		attributes = append(attributes, otlpAttribute("goobserv.request_id", entry.RequestID))
+	}
+	if entry.State != "" {
+		attributes = append(attributes, otlpAttribute("goobserv.state", entry.State))
+	}
+	end := endTime(entry.StartTime, entry.EndTime, entry.Duration)
+
+	if entry.Error != nil {
+		records = append(records, newOTLPLogRecord(end, "error", entry.Error.Message, traceID, rootID, attributes))
+	}
+	if len(records) == n {
+		// standalone record for requests without events
+		level := "info"
+		if entry.State == "error" {
+			level = "error"
+		}
+		body := strings.TrimSpace(strings.Join([]string{entry.Method, entry.OriginalPath, entry.State}, " "))
+		records = append(records, newOTLPLogRecord(end, level, body, traceID, rootID, attributes))
+	}
+	return records
+}
+
+func newOTLPLogRecord(t time.Time, level, message, traceID, spanID string, attributes []otlpKeyValue) otlpLogRecord {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return otlpLogRecord{
+		TimeUnixNano:         otlpTime(t),
+		ObservedTimeUnixNano: otlpTime(time.Now()),
+		SeverityNumber:       otlpSeverity(level),
+		SeverityText:         level,
+		Body:                 otlpValue(message),
+		Attributes:           attributes,
+		TraceID:              traceID,
+		SpanID:               spanID,
+	}
+}
+
+// otlpSeverity maps an event level to an OTLP severity number
+func otlpSeverity(level string) int {
+	switch strings.ToLower(level) {
+	case "debug":
+		return otlpSeverityDebug
+	case "info":
+		return otlpSeverityInfo
+	case "warn", "warning":
+		return otlpSeverityWarn
+	case "error":
+		return otlpSeverityError
+	default:
+		return 0
+	}
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
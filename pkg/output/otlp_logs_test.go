@@ -0,0 +1,71 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTLPLogOutput(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Standalone records", func(t *testing.T) {
+		stub := newCollectorStub(t)
+		output := NewOTLPLogOutput(fastOTLPConfig(stub.URL))
+		defer output.Close()
+
+		assert.NoError(t, output.Write([]*core.Entry{{
+			TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+			StartTime:    start,
+			Duration:     0.5,
+			State:        "error",
+			Method:       "POST",
+			OriginalPath: "/api/users",
+		}}))
+
+		req := stub.last()
+		assert.Equal(t, "/v1/logs", req.Path)
+		assert.Equal(t, "user-service", jsonPath(req.Body, "resourceLogs", 0, "resource", "attributes", 0, "value", "stringValue"))
+		scope := jsonPath(req.Body, "resourceLogs", 0, "scopeLogs", 0)
+		assert.Equal(t, otlpScopeName, jsonPath(scope, "scope", "name"))
+
+		record := jsonPath(scope, "logRecords", 0)
+		assert.Equal(t, "1704164645500000000", jsonPath(record, "timeUnixNano"))
+		assert.Equal(t, float64(otlpSeverityError), jsonPath(record, "severityNumber"))
+		assert.Equal(t, "error", jsonPath(record, "severityText"))
+		assert.Equal(t, "POST /api/users error", jsonPath(record, "body", "stringValue"))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", jsonPath(record, "traceId"))
+		assert.Equal(t, otlpSpanID("4bf92f3577b34da6a3ce929d0e0e4736", "\x00root"), jsonPath(record, "spanId"),
+			"Request records should point at the request span exported by the trace output")
+		assert.Nil(t, jsonPath(scope, "logRecords", 1))
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		stub := newCollectorStub(t)
+		config := fastOTLPConfig(stub.URL)
+		config.MaxBatchSize = 2
+		output := NewOTLPLogOutput(config)
+		defer output.Close()
+
+		assert.NoError(t, output.Write(newQueueEntries(5)))
+		assert.Equal(t, int32(3), stub.calls.Load())
+		assert.Len(t, jsonPath(stub.last().Body, "resourceLogs", 0, "scopeLogs", 0, "logRecords"), 1)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		stub := newCollectorStub(t, 400)
+		output := NewOTLPLogOutput(fastOTLPConfig(stub.URL))
+		defer output.Close()
+		assert.True(t, IsPermanent(output.Write(newQueueEntries(1))))
+	})
+
+	t.Run("Severity", func(t *testing.T) {
+		assert.Equal(t, otlpSeverityDebug, otlpSeverity("debug"))
+		assert.Equal(t, otlpSeverityInfo, otlpSeverity("info"))
+		assert.Equal(t, otlpSeverityWarn, otlpSeverity("WARN"))
+		assert.Equal(t, otlpSeverityError, otlpSeverity("error"))
+		assert.Zero(t, otlpSeverity("custom"))
+	})
+}
@@ -0,0 +1,246 @@
+package output
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// collectorStub records OTLP requests and replies with the queued status codes
+type collectorStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []collectedRequest
+	statuses []int
+	calls    atomic.Int32
+}
+
+type collectedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+func newCollectorStub(t *testing.T, statuses ...int) *collectorStub {
+	stub := &collectorStub{statuses: statuses}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(stub.calls.Add(1)) - 1
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			reader = gz
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(reader).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, collectedRequest{Path: r.URL.Path, Header: r.Header, Body: body})
+		stub.mu.Unlock()
+
+		if n < len(stub.statuses) {
+			w.WriteHeader(stub.statuses[n])
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *collectorStub) last() collectedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+// jsonPath walks decoded JSON by map keys and slice indexes
+func jsonPath(v interface{}, keys ...interface{}) interface{} {
+	for _, key := range keys {
+		switch k := key.(type) {
+		case string:
+			m, _ := v.(map[string]interface{})
+			v = m[k]
+		case int:
+			s, _ := v.([]interface{})
+			if k >= len(s) {
+				return nil
+			}
+			v = s[k]
+		}
+	}
+	return v
+}
+
+func fastOTLPConfig(endpoint string) OTLPConfig {
+	return OTLPConfig{
+		Endpoint:    endpoint,
+		ServiceName: "user-service",
+		Resource:    map[string]interface{}{"deployment.environment": "test"},
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		Retry:       RetryConfig{InitialBackoff: time.Millisecond},
+	}
+}
+
+func TestOTLPTraceOutput(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Export", func(t *testing.T) {
+		stub := newCollectorStub(t)
+		output := NewOTLPTraceOutput(fastOTLPConfig(stub.URL))
+		defer output.Close()
+
+		assert.NoError(t, output.Write([]*core.Entry{{
+			TraceID:      "4bf92f35-77b3-4da6-a3ce-929d0e0e4736",
+			RequestID:    "req-1",
+			StartTime:    start,
+			Duration:     0.25,
+			State:        "error",
+			Method:       "GET",
+			OriginalPath: "/api/users",
+		}}))
+
+		req := stub.last()
+		assert.Equal(t, "/v1/traces", req.Path)
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+		resource := jsonPath(req.Body, "resourceSpans", 0, "resource", "attributes")
+		assert.Equal(t, "service.name", jsonPath(resource, 0, "key"))
+		assert.Equal(t, "user-service", jsonPath(resource, 0, "value", "stringValue"))
+		assert.Equal(t, "deployment.environment", jsonPath(resource, 1, "key"))
+
+		scope := jsonPath(req.Body, "resourceSpans", 0, "scopeSpans", 0)
+		assert.Equal(t, otlpScopeName, jsonPath(scope, "scope", "name"))
+		span := jsonPath(scope, "spans", 0)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", jsonPath(span, "traceId"), "UUID trace IDs should be kept")
+		assert.Len(t, jsonPath(span, "spanId"), 16)
+		assert.Nil(t, jsonPath(span, "parentSpanId"), "Request span should be the root")
+		assert.Equal(t, "GET /api/users", jsonPath(span, "name"))
+		assert.Equal(t, float64(otlpSpanKindServer), jsonPath(span, "kind"))
+		assert.Equal(t, "1704164645000000000", jsonPath(span, "startTimeUnixNano"))
+		assert.Equal(t, "1704164645250000000", jsonPath(span, "endTimeUnixNano"), "End should be derived from the duration")
+		assert.Equal(t, float64(otlpStatusError), jsonPath(span, "status", "code"))
+		assert.Equal(t, "http.request.method", jsonPath(span, "attributes", 0, "key"))
+	})
+
+	t.Run("Retries unavailable collector", func(t *testing.T) {
+		stub := newCollectorStub(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		output := NewOTLPTraceOutput(fastOTLPConfig(stub.URL))
+		defer output.Close()
+
+		assert.NoError(t, output.Write(newQueueEntries(1)))
+		assert.Equal(t, int32(3), stub.calls.Load())
+	})
+
+	t.Run("Rejected payload is permanent", func(t *testing.T) {
+		stub := newCollectorStub(t, http.StatusBadRequest)
+		output := NewOTLPTraceOutput(fastOTLPConfig(stub.URL))
+		defer output.Close()
+
+		err := output.Write(newQueueEntries(1))
+		assert.True(t, IsPermanent(err))
+		assert.Equal(t, int32(1), stub.calls.Load())
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		stub := newCollectorStub(t, 503, 503, 503, 503)
+		config := fastOTLPConfig(stub.URL)
+		config.Uncompressed = true
+		output := NewOTLPTraceOutput(config)
+		defer output.Close()
+
+		var writeErr *WriteError
+		assert.ErrorAs(t, output.Write(newQueueEntries(1)), &writeErr)
+		assert.Equal(t, 3, writeErr.Attempts)
+		assert.Equal(t, "otlp", writeErr.Output)
+		assert.Empty(t, stub.last().Header.Get("Content-Encoding"))
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		stub := newCollectorStub(t, http.StatusOK, http.StatusBadRequest)
+		config := fastOTLPConfig(stub.URL)
+		config.MaxBatchSize = 2
+		output := NewOTLPTraceOutput(config)
+		defer output.Close()
+
+		// each entry exports its root span and one child
+		entries := newQueueEntries(3)
+		for _, entry := range entries {
+			entry.Spans = slices.Grow(entry.Spans, 1)[:1]
+			entry.Spans[0].Function = "query"
+		}
+		var partial *PartialWriteError
+		assert.ErrorAs(t, output.Write(entries), &partial)
+		assert.Equal(t, entries[1:], partial.Remaining, "Exported entries should not be returned")
+		assert.True(t, IsPermanent(partial))
+
+		err := errors.New("unavailable")
+		assert.Same(t, err, otlpWriteError(entries, []int{0, 3, 3}, 0, err), "Nothing exported should not be partial")
+		assert.ErrorAs(t, otlpWriteError(entries, []int{0, 3, 3}, 2, err), &partial)
+		assert.Equal(t, entries, partial.Remaining, "An entry split across requests should be returned")
+		assert.ErrorAs(t, otlpWriteError(entries, []int{0, 3, 3}, 3, err), &partial)
+		assert.Equal(t, entries[2:], partial.Remaining, "Entries without items should be skipped")
+	})
+
+	t.Run("Retry-After", func(t *testing.T) {
+		output := NewOTLPTraceOutput(OTLPConfig{Retry: RetryConfig{MaxBackoff: time.Minute}})
+		defer output.Close()
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		assert.Equal(t, 5*time.Second, output.retryAfter("5", now))
+		assert.Equal(t, 30*time.Second, output.retryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now), "HTTP dates should be parsed")
+		assert.Equal(t, time.Minute, output.retryAfter("3600", now), "Delay should be clamped to MaxBackoff")
+		assert.Equal(t, time.Minute, output.retryAfter(now.Add(time.Hour).Format(http.TimeFormat), now))
+		assert.Zero(t, output.retryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now), "Past dates should not delay")
+		assert.Zero(t, output.retryAfter("-1", now))
+		assert.Zero(t, output.retryAfter("soon", now))
+	})
+}
+
+func TestOTLPValues(t *testing.T) {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(otlpValue(v))
+		return string(data)
+	}
+	assert.Equal(t, `{"stringValue":"x"}`, encode("x"))
+	assert.Equal(t, `{"intValue":"42"}`, encode(42))
+	assert.Equal(t, `{"intValue":"9223372036854775807"}`, encode(uint64(math.MaxInt64)))
+	assert.Equal(t, `{"stringValue":"18446744073709551615"}`, encode(uint64(math.MaxUint64)), "Values over MaxInt64 should not wrap")
+	assert.Equal(t, `{"stringValue":"18446744073709551615"}`, encode(uint(math.MaxUint)))
+	assert.Equal(t, `{"doubleValue":1.5}`, encode(1.5))
+	assert.Equal(t, `{"boolValue":false}`, encode(false))
+	assert.Equal(t, `{"arrayValue":{"values":[{"stringValue":"a"},{"intValue":"1"}]}}`, encode([]interface{}{"a", 1}))
+	assert.Equal(t, `{"kvlistValue":{"values":[{"key":"a","value":{"intValue":"1"}}]}}`, encode(map[string]interface{}{"a": 1}))
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", otlpTraceID("4BF92F3577B34DA6A3CE929D0E0E4736"))
+	assert.Equal(t, otlpTraceID("trace-1"), otlpTraceID("trace-1"), "Hashed trace IDs should be stable")
+	assert.Len(t, otlpTraceID("trace-1"), 32)
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := &core.Entry{RequestID: "req-1", StartTime: start}
+	assert.Equal(t, otlpEntryTraceID(entry), otlpEntryTraceID(&core.Entry{RequestID: "req-1", StartTime: start}), "Missing trace IDs should be derived from the request")
+	assert.NotEqual(t, otlpEntryTraceID(entry), otlpEntryTraceID(&core.Entry{RequestID: "req-2", StartTime: start}))
+	assert.NotEqual(t, otlpEntryTraceID(entry), otlpEntryTraceID(&core.Entry{RequestID: "req-1", StartTime: start.Add(time.Nanosecond)}))
+	assert.Equal(t, otlpTraceID("trace-1"), otlpEntryTraceID(&core.Entry{TraceID: "trace-1", RequestID: "req-1"}))
+	assert.Equal(t, "00f067aa0ba902b7", otlpSpanID("trace", "00f067aa0ba902b7"))
+	assert.NotEqual(t, otlpSpanID("a", "1"), otlpSpanID("b", "1"))
+	assert.Len(t, otlpSpanID("a", "1"), 16)
+}
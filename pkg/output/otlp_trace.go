@@ -0,0 +1,161 @@
+package output
+
+import (
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const otlpTracesPath = "/v1/traces"
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+
+	otlpStatusUnset = 0
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+// OTLPTraceOutput exports entries as OTLP traces to a collector's /v1/traces.
+// Each entry becomes a server span for the request with its spans as children;
+// span events become OTLP span events and span input and output become
+// attributes. Wrap it in a QueuedOutput to batch and export in the background.
+type OTLPTraceOutput struct {
+	*otlpExporter
+}
+
+// NewOTLPTraceOutput creates an OTLP trace exporter
+func NewOTLPTraceOutput(config OTLPConfig) *OTLPTraceOutput {
+	return &OTLPTraceOutput{otlpExporter: newOTLPExporter(config, otlpTracesPath)}
+}
+
+// Write exports the spans of entries, see exportEntries
+func (o *OTLPTraceOutput) Write(entries []*core.Entry) error {
+	return exportEntries(o.otlpExporter, entries, appendOTLPSpans, func(spans []otlpSpan) interface{} {
+		return otlpTraceRequest{
+			ResourceSpans: []otlpResourceSpans{{
+				Resource: o.resource(),
+				ScopeSpans: []otlpScopeSpans{{
+					Scope: otlpScope{Name: otlpScopeName},
+					Spans: spans,
+				}},
+			}},
+		}
+	})
+}
+
+// appendOTLPSpans converts an entry into a root span followed by its children
+func appendOTLPSpans(spans []otlpSpan, entry *core.Entry) []otlpSpan {
+	traceID := otlpEntryTraceID(entry)
+	rootID := otlpSpanID(traceID, "\x00root")
+
+	name := entry.OriginalPath
+	if entry.Method != "" {
+		name = entry.Method + " " + entry.OriginalPath
+	}
+	root := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            rootID,
+		Name:              name,
+		Kind:              otlpSpanKindServer,
+		StartTimeUnixNano: otlpTime(entry.StartTime),
+		EndTimeUnixNano:   otlpTime(endTime(entry.StartTime, entry.EndTime, entry.Duration)),
+	}
+	if entry.Method != "" {
+		root.Attributes = append(root.Attributes, otlpAttribute("http.request.method", entry.Method))
+	}
+	if entry.OriginalPath != "" {
+		root.Attributes = append(root.Attributes, otlpAttribute("url.path", entry.OriginalPath))
+	}
+	if entry.RequestID != "" {
+		root.Attributes = append(root.Attributes, otlpAttribute("goobserv.request_id", entry.RequestID))
+	}
+	if entry.TraceID != "" && entry.TraceID != traceID {
+		root.Attributes = append(root.Attributes, otlpAttribute("goobserv.trace_id", entry.TraceID))
+	}
+	if entry.State != "" {
+		root.Attributes = append(root.Attributes, otlpAttribute("goobserv.state", entry.State))
+	}
+	switch {
+	case entry.Error != nil:
+		root.Status = otlpStatus{Code: otlpStatusError, Message: entry.Error.Message}
+	case entry.State == "error":
+		root.Status = otlpStatus{Code: otlpStatusError}
+	case entry.State == "success":
+		root.Status = otlpStatus{Code: otlpStatusOK}
+	}
+	spans = append(spans, root)
+
+	for _, span := range entry.Spans {
+		child := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            otlpSpanID(traceID, span.SpanID),
+			ParentSpanID:      rootID,
+			Name:              span.Function,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: otlpTime(span.StartTime),
+			EndTimeUnixNano:   otlpTime(endTime(span.StartTime, span.EndTime, span.Duration)),
+			Attributes:        append(otlpAttributes("input.", span.Input), otlpAttributes("output.", span.Output)...),
+		}
+		if span.Event != nil {
+			child.Events = []otlpSpanEvent{{
+				TimeUnixNano: child.EndTimeUnixNano,
+				Name:         span.Event.Message,
+				Attributes:   []otlpKeyValue{otlpAttribute("level", span.Event.Level)},
+			}}
+			if span.Event.Level == "error" {
+				child.Status = otlpStatus{Code: otlpStatusError, Message: span.Event.Message}
+			}
+		}
+		spans = append(spans, child)
+	}
+	return spans
+}
+
+// endTime returns end, or start plus duration seconds when end is unset
+func endTime(start, end time.Time, duration float64) time.Time {
+	if end.IsZero() && !start.IsZero() {
+		return start.Add(time.Duration(duration * float64(time.Second)))
+	}
+	return end
+}
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue  `json:"attributes,omitempty"`
+	Events            []otlpSpanEvent `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpSpanEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
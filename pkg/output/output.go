@@ -1,6 +1,8 @@
 package output
 
 import (
+	"time"
+
 	"github.com/nat-prohmpiriya/goobserv/pkg/core"
 )
 
@@ -16,6 +18,22 @@ type Output interface {
 	Close() error
 }
 
+// MetadataWriter is implemented by outputs that can attach extra metadata,
+// such as a diversion marker, to the entries they write
+type MetadataWriter interface {
+	// WriteWithMetadata writes entries with metadata added to each of them
+	WriteWithMetadata(entries []*core.Entry, metadata map[string]interface{}) error
+}
+
+// writeWithMetadata writes entries with metadata if output supports it,
+// otherwise the metadata is dropped
+func writeWithMetadata(output Output, entries []*core.Entry, metadata map[string]interface{}) error {
+	if w, ok := output.(MetadataWriter); ok {
+		return w.WriteWithMetadata(entries, metadata)
+	}
+	return output.Write(entries)
+}
+
 // LogEntry represents a log entry for output
 type LogEntry struct {
 	TraceID      string                 `json:"trace_id,omitempty"`
@@ -29,6 +47,10 @@ type LogEntry struct {
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 	Errors       []string               `json:"errors,omitempty"`
 	Spans        []*SpanEntry           `json:"spans,omitempty"`
+	Sampling     *SamplingEntry         `json:"sampling,omitempty"`
+	Truncated    bool                   `json:"truncated,omitempty"`
+
+	start time.Time // StartTime before formatting, for sub-second offsets on the console
 }
 
 // SpanEntry represents a span entry for output
@@ -41,6 +63,8 @@ type SpanEntry struct {
 	Output    map[string]interface{} `json:"output,omitempty"`
 	Event     *EventEntry            `json:"event,omitempty"`
 	SpanID    string                 `json:"span_id"`
+
+	start time.Time // StartTime before formatting, for sub-second offsets on the console
 }
 
 // EventEntry represents an event entry for output
@@ -48,3 +72,9 @@ type EventEntry struct {
 	Level   string `json:"level"`
 	Message string `json:"message"`
 }
+
+// SamplingEntry represents a tail sampling decision for output
+type SamplingEntry struct {
+	Sampled bool   `json:"sampled"`
+	Reason  string `json:"reason"`
+}
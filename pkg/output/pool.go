@@ -0,0 +1,115 @@
+package output
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+// maxPooledBufferSize keeps unusually large buffers out of the pool
+const maxPooledBufferSize = 64 << 10
+
+// encodeBuffer is a reusable byte slice for encoding entries
+type encodeBuffer struct {
+	bytes []byte
+}
+
+var (
+	encodeBufferPool = sync.Pool{
+		New: func() interface{} { return new(encodeBuffer) },
+	}
+	logEntryPool = sync.Pool{
+		New: func() interface{} { return new(LogEntry) },
+	}
+	spanEntryPool = sync.Pool{
+		New: func() interface{} { return new(SpanEntry) },
+	}
+)
+
+// getEncodeBuffer returns an empty buffer from the pool
+func getEncodeBuffer() *encodeBuffer {
+	b := encodeBufferPool.Get().(*encodeBuffer)
+	b.bytes = b.bytes[:0]
+	return b
+}
+
+// putEncodeBuffer returns a buffer to the pool
+func putEncodeBuffer(b *encodeBuffer) {
+	if cap(b.bytes) > maxPooledBufferSize {
+		return
+	}
+	encodeBufferPool.Put(b)
+}
+
+// acquireLogEntry converts a core entry into a pooled log entry.
+// The log entry must be released with releaseLogEntry once encoded.
+func acquireLogEntry(entry *core.Entry) *LogEntry {
+	logEntry := logEntryPool.Get().(*LogEntry)
+	logEntry.TraceID = entry.TraceID
+	logEntry.RequestID = entry.RequestID
+	logEntry.StartTime = entry.StartTime.Format(time.RFC3339)
+	logEntry.start = entry.StartTime
+	logEntry.EndTime = entry.EndTime.Format(time.RFC3339)
+	logEntry.Duration = entry.Duration
+	logEntry.State = entry.State
+	logEntry.Method = entry.Method
+	logEntry.OriginalPath = entry.OriginalPath
+	if logEntry.Metadata == nil {
+		logEntry.Metadata = make(map[string]interface{})
+	}
+
+	// Convert spans
+	for _, span := range entry.Spans {
+		spanEntry := spanEntryPool.Get().(*SpanEntry)
+		spanEntry.Function = span.Function
+		spanEntry.StartTime = span.StartTime.Format(time.RFC3339)
+		spanEntry.start = span.StartTime
+		spanEntry.EndTime = span.EndTime.Format(time.RFC3339)
+		spanEntry.Duration = span.Duration
+		spanEntry.Input = span.Input
+		spanEntry.Output = span.Output
+		spanEntry.SpanID = span.SpanID
+
+		if span.Event != nil {
+			spanEntry.Event = &EventEntry{
+				Level:   span.Event.Level,
+				Message: span.Event.Message,
+			}
+		}
+
+		logEntry.Spans = append(logEntry.Spans, spanEntry)
+	}
+
+	if entry.Sampling != nil {
+		logEntry.Sampling = &SamplingEntry{
+			Sampled: entry.Sampling.Sampled,
+			Reason:  entry.Sampling.Reason,
+		}
+	}
+
+	// Add error if present
+	if entry.Error != nil {
+		logEntry.Errors = append(logEntry.Errors, entry.Error.Message)
+		logEntry.State = "error"
+	}
+
+	return logEntry
+}
+
+// releaseLogEntry resets a log entry and its spans and returns them to the pool
+func releaseLogEntry(logEntry *LogEntry) {
+	for _, spanEntry := range logEntry.Spans {
+		*spanEntry = SpanEntry{}
+		spanEntryPool.Put(spanEntry)
+	}
+	metadata, errors, spans := logEntry.Metadata, logEntry.Errors, logEntry.Spans
+	clear(metadata)
+	clear(spans)
+	*logEntry = LogEntry{
+		Metadata: metadata,
+		Errors:   errors[:0],
+		Spans:    spans[:0],
+	}
+	logEntryPool.Put(logEntry)
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBenchEntry() *core.Entry {
+	now := time.Now()
+	return &core.Entry{
+		TraceID:      "trace-1",
+		RequestID:    "req-1",
+		StartTime:    now,
+		EndTime:      now.Add(25 * time.Millisecond),
+		Duration:     0.025,
+		State:        "success",
+		Method:       "GET",
+		OriginalPath: "/api/users",
+	}
+}
+
+func TestLogEntryPool(t *testing.T) {
+	logEntry := acquireLogEntry(newBenchEntry())
+	logEntry.Metadata["key"] = "value"
+	logEntry.Errors = append(logEntry.Errors, "failed")
+	logEntry.Spans = append(logEntry.Spans, &SpanEntry{Function: "handler"})
+	releaseLogEntry(logEntry)
+
+	assert.Empty(t, logEntry.TraceID, "Released entry should be reset")
+	assert.Empty(t, logEntry.Metadata, "Released metadata should be cleared")
+	assert.Empty(t, logEntry.Errors)
+	assert.Empty(t, logEntry.Spans)
+
+	buf := getEncodeBuffer()
+	buf.bytes = append(buf.bytes, "stale"...)
+	putEncodeBuffer(buf)
+	assert.Empty(t, getEncodeBuffer().bytes, "Buffer from pool should be empty")
+}
+
+func BenchmarkStdoutWrite(b *testing.B) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+
+	output := NewWriterOutput(WriterConfig{Writer: devNull})
+	entries := []*core.Entry{newBenchEntry()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := output.Write(entries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStdoutWriteUnpooled encodes the way StdoutOutput did before pooling
+func BenchmarkStdoutWriteUnpooled(b *testing.B) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+
+	entry := newBenchEntry()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logEntry := LogEntry{
+			TraceID:      entry.TraceID,
+			RequestID:    entry.RequestID,
+			StartTime:    entry.StartTime.Format(time.RFC3339),
+			EndTime:      entry.EndTime.Format(time.RFC3339),
+			Duration:     entry.Duration,
+			State:        entry.State,
+			Method:       entry.Method,
+			OriginalPath: entry.OriginalPath,
+			Metadata:     make(map[string]interface{}),
+			Errors:       make([]string, 0),
+		}
+		if err := json.NewEncoder(devNull).Encode(logEntry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/buffer"
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const defaultQueueSize = 1000
+
+// QueueConfig represents queued output configuration
+type QueueConfig struct {
+	Size     int                                    // entries held before the overflow policy applies
+	Batch    BatchConfig                            // batching limits for this output
+	Overflow buffer.Policy                          // what to drop when the queue is full
+	OnError  func(err error, entries []*core.Entry) // called when the wrapped output fails, must not retain entries
+}
+
+// QueueStats represents queued output statistics
+type QueueStats struct {
+	Depth       int
+	Capacity    int
+	Enqueued    uint64
+	Written     uint64
+	Failed      uint64 // entries in batches the wrapped output failed to write
+	Dropped     uint64 // entries rejected while the queue was full
+	Overwritten uint64 // entries discarded to make room under buffer.DropOldest
+	Batches     uint64
+}
+
+// QueuedOutput wraps an output with its own bounded queue and worker goroutine,
+// so a slow or failing output cannot hold up the others. Write never blocks;
+// entries are written asynchronously and must not be released to the pool
+// while they are queued.
+type QueuedOutput struct {
+	output Output
+	config QueueConfig
+	queue  *buffer.Ring[*core.Entry]
+	batch  *Batcher
+
+	notify  chan struct{}
+	flushes chan chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	enqueued atomic.Uint64
+	written  atomic.Uint64
+	failed   atomic.Uint64
+	batches  atomic.Uint64
+}
+
+// NewQueuedOutput creates a queued output and starts its worker
+func NewQueuedOutput(output Output, config QueueConfig) *QueuedOutput {
+	if config.Size <= 0 {
+		config.Size = defaultQueueSize
+	}
+	o := &QueuedOutput{
+		output:  output,
+		config:  config,
+		queue:   buffer.NewRing[*core.Entry](config.Size, config.Overflow),
+		batch:   NewBatcher(config.Batch),
+		notify:  make(chan struct{}, 1),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	o.wg.Add(1)
+	go o.run()
+	return o
+}
+
+// Write queues entries for the worker
+func (o *QueuedOutput) Write(entries []*core.Entry) error {
+	for _, entry := range entries {
+		if o.queue.Push(entry) {
+			o.enqueued.Add(1)
+		}
+	}
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Flush writes all queued entries and flushes the wrapped output
+func (o *QueuedOutput) Flush() error {
+	reply := make(chan error, 1)
+	select {
+	case o.flushes <- reply:
+		return <-reply
+	case <-o.done:
+		return nil
+	}
+}
+
+// Close stops the worker after writing queued entries and closes the wrapped output
+func (o *QueuedOutput) Close() error {
+	o.once.Do(func() {
+		close(o.done)
+	})
+	o.wg.Wait()
+	return o.output.Close()
+}
+
+// Stats returns queue statistics
+func (o *QueuedOutput) Stats() QueueStats {
+	return QueueStats{
+		Depth:       o.queue.Len(),
+		Capacity:    o.queue.Cap(),
+		Enqueued:    o.enqueued.Load(),
+		Written:     o.written.Load(),
+		Failed:      o.failed.Load(),
+		Dropped:     o.queue.Drops(),
+		Overwritten: o.queue.Overwrites(),
+		Batches:     o.batches.Load(),
+	}
+}
+
+// run is the worker loop, the only consumer of the queue and the batcher
+func (o *QueuedOutput) run() {
+	defer o.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	var batch []*core.Entry
+
+	for {
+		select {
+		case <-o.notify:
+		case <-timer.C:
+		case reply := <-o.flushes:
+			batch = o.drain(batch, true)
+			reply <- o.output.Flush()
+			continue
+		case <-o.done:
+			o.drain(batch, true)
+			o.output.Flush()
+			return
+		}
+		batch = o.drain(batch, false)
+
+		// Wake up when the oldest pending entry reaches MaxWait. The timer is
+		// never drained, a stale tick only causes an extra, harmless drain.
+		if deadline := o.batch.Deadline(); !deadline.IsZero() {
+			timer.Reset(time.Until(deadline))
+		} else {
+			timer.Stop()
+		}
+	}
+}
+
+// drain moves queued entries into the batcher and writes ready batches,
+// or everything pending when force is set
+func (o *QueuedOutput) drain(batch []*core.Entry, force bool) []*core.Entry {
+	for {
+		entry, ok := o.queue.Pop()
+		if !ok {
+			break
+		}
+		o.batch.Add(entry, time.Now())
+		batch = o.writeReady(batch, false)
+	}
+	batch = o.writeReady(batch, force)
+	clear(batch)
+	return batch[:0]
+}
+
+// writeReady writes batches while the batcher has one ready
+func (o *QueuedOutput) writeReady(batch []*core.Entry, force bool) []*core.Entry {
+	for o.batch.Len() > 0 && (force || o.batch.Ready(time.Now())) {
+		batch = o.batch.Take(batch[:0])
+		o.write(batch)
+	}
+	return batch
+}
+
+func (o *QueuedOutput) write(batch []*core.Entry) {
+	o.batches.Add(1)
+	if err := o.output.Write(batch); err != nil {
+		o.failed.Add(uint64(len(batch)))
+		if o.config.OnError != nil {
+			o.config.OnError(err, batch)
+		}
+		return
+	}
+	o.written.Add(uint64(len(batch)))
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/buffer"
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingOutput blocks every Write until released
+type blockingOutput struct {
+	TestOutput
+	release chan struct{}
+}
+
+func (o *blockingOutput) Write(entries []*core.Entry) error {
+	<-o.release
+	return o.TestOutput.Write(entries)
+}
+
+// failingOutput fails every Write
+type failingOutput struct {
+	TestOutput
+}
+
+func (o *failingOutput) Write(entries []*core.Entry) error {
+	return errors.New("sink unavailable")
+}
+
+func newQueueEntries(n int) []*core.Entry {
+	entries := make([]*core.Entry, n)
+	for i := range entries {
+		entries[i] = &core.Entry{TraceID: "trace", State: "success"}
+	}
+	return entries
+}
+
+func TestQueuedOutput(t *testing.T) {
+	t.Run("Batches and flush", func(t *testing.T) {
+		target := NewTestOutput()
+		queued := NewQueuedOutput(target, QueueConfig{Batch: BatchConfig{MaxCount: 2, MaxWait: time.Hour}})
+
+		assert.NoError(t, queued.Write(newQueueEntries(5)))
+		assert.NoError(t, queued.Flush())
+
+		total, _, _ := target.Stats()
+		assert.Equal(t, 5, total, "Flush should write all queued entries")
+		stats := queued.Stats()
+		assert.Equal(t, uint64(5), stats.Enqueued)
+		assert.Equal(t, uint64(5), stats.Written)
+		assert.Equal(t, 0, stats.Depth)
+		assert.NoError(t, queued.Close())
+	})
+
+	t.Run("Batch wait", func(t *testing.T) {
+		target := NewTestOutput()
+		queued := NewQueuedOutput(target, QueueConfig{Batch: BatchConfig{MaxCount: 100, MaxWait: 10 * time.Millisecond}})
+		defer queued.Close()
+
+		queued.Write(newQueueEntries(1))
+		assert.Eventually(t, target.HasEntries, time.Second, 5*time.Millisecond,
+			"Partial batch should be written after MaxWait")
+	})
+
+	t.Run("Slow output does not block", func(t *testing.T) {
+		slow := &blockingOutput{release: make(chan struct{})}
+		fast := NewTestOutput()
+		slowQueue := NewQueuedOutput(slow, QueueConfig{Size: 4, Batch: BatchConfig{MaxCount: 1}, Overflow: buffer.DropOldest})
+		fastQueue := NewQueuedOutput(fast, QueueConfig{Batch: BatchConfig{MaxCount: 1}})
+
+		for i := 0; i < 10; i++ {
+			entries := newQueueEntries(1)
+			slowQueue.Write(entries)
+			fastQueue.Write(entries)
+		}
+		assert.NoError(t, fastQueue.Flush())
+		total, _, _ := fast.Stats()
+		assert.Equal(t, 10, total, "Fast output should receive all entries")
+		assert.Greater(t, slowQueue.Stats().Overwritten, uint64(0), "Slow queue should overflow")
+
+		close(slow.release)
+		assert.NoError(t, slowQueue.Close())
+		assert.NoError(t, fastQueue.Close())
+	})
+
+	t.Run("Write errors", func(t *testing.T) {
+		var reported int
+		queued := NewQueuedOutput(&failingOutput{}, QueueConfig{
+			OnError: func(err error, entries []*core.Entry) {
+				reported += len(entries)
+			},
+		})
+
+		queued.Write(newQueueEntries(3))
+		assert.NoError(t, queued.Close())
+		assert.Equal(t, uint64(3), queued.Stats().Failed)
+		assert.Equal(t, 3, reported)
+	})
+}
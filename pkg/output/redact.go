@@ -0,0 +1,335 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// MaskStyle represents how a sensitive value is masked
+type MaskStyle int
+
+const (
+	// MaskFull replaces the whole value with the mask pattern
+	MaskFull MaskStyle = iota
+	// MaskPartial keeps the last four characters visible
+	MaskPartial
+	// MaskHash replaces the value with a short sha256 digest
+	MaskHash
+)
+
+const (
+	defaultMaskPattern  = "***"
+	partialVisibleChars = 4
+	hashPrefixLength    = 12
+)
+
+// DefaultSensitiveKeys are key names masked when no keys are configured
+var DefaultSensitiveKeys = []string{"password", "token", "authorization"}
+
+// RedactPattern represents a value pattern to be masked
+type RedactPattern struct {
+	Name     string
+	Regexp   *regexp.Regexp
+	Validate func(match string) bool // optional, rejects false positives
+}
+
+// Built-in value patterns
+var (
+	PatternThaiID = &RedactPattern{
+		Name:     "thai_id",
+		Regexp:   regexp.MustCompile(`\b\d[ -]?\d{4}[ -]?\d{5}[ -]?\d{2}[ -]?\d\b`),
+		Validate: validThaiID,
+	}
+	PatternCreditCard = &RedactPattern{
+		Name:     "credit_card",
+		Regexp:   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: validLuhn,
+	}
+	PatternEmail = &RedactPattern{
+		Name:   "email",
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+	PatternJWT = &RedactPattern{
+		Name:   "jwt",
+		Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	}
+)
+
+// DefaultRedactPatterns are value patterns used when no patterns are configured.
+// Thai national IDs are checked before credit cards since both are digit runs.
+var DefaultRedactPatterns = []*RedactPattern{
+	PatternThaiID,
+	PatternCreditCard,
+	PatternEmail,
+	PatternJWT,
+}
+
+// RedactConfig represents redaction configuration
+type RedactConfig struct {
+	SensitiveKeys []string         // key names to mask, matched case-insensitively as whole key segments
+	Patterns      []*RedactPattern // value patterns to mask inside strings
+	MaskStyle     MaskStyle
+	MaskPattern   string              // replacement for MaskFull, defaults to "***"
+	MaskFunc      func(string) string // custom masking, overrides MaskStyle
+}
+
+// sensitiveKey represents a configured key name split into segments
+type sensitiveKey struct {
+	name     string
+	segments []string
+}
+
+// Redactor masks sensitive data in log entries
+type Redactor struct {
+	config   RedactConfig
+	keys     []sensitiveKey
+	patterns []*RedactPattern
+	hits     map[string]*uint64
+}
+
+// NewRedactor creates a new redactor
+func NewRedactor(config RedactConfig) *Redactor {
+	if config.MaskPattern == "" {
+		config.MaskPattern = defaultMaskPattern
+	}
+	if config.SensitiveKeys == nil {
+		config.SensitiveKeys = DefaultSensitiveKeys
+	}
+	if config.Patterns == nil {
+		config.Patterns = DefaultRedactPatterns
+	}
+
+	r := &Redactor{
+		config:   config,
+		patterns: config.Patterns,
+		hits:     make(map[string]*uint64),
+	}
+	for _, key := range config.SensitiveKeys {
+		key = strings.ToLower(key)
+		r.keys = append(r.keys, sensitiveKey{name: key, segments: keySegments(key)})
+		r.hits[key] = new(uint64)
+	}
+	for _, pattern := range r.patterns {
+		r.hits[pattern.Name] = new(uint64)
+	}
+	return r
+}
+
+// Redact masks sensitive values in the entry metadata, span input/output and events.
+// Maps are copied before masking so the source entry is never modified.
+func (r *Redactor) Redact(entry *LogEntry) {
+	entry.Metadata = r.redactMap(entry.Metadata)
+	for i, msg := range entry.Errors {
+		entry.Errors[i] = r.redactString(msg)
+	}
+	for _, span := range entry.Spans {
+		span.Input = r.redactMap(span.Input)
+		span.Output = r.redactMap(span.Output)
+		if span.Event != nil {
+			span.Event.Message = r.redactString(span.Event.Message)
+		}
+	}
+}
+
+// Stats returns the number of masked values per key name and pattern
+func (r *Redactor) Stats() map[string]uint64 {
+	stats := make(map[string]uint64, len(r.hits))
+	for name, count := range r.hits {
+		stats[name] = atomic.LoadUint64(count)
+	}
+	return stats
+}
+
+func (r *Redactor) redactMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if key, ok := r.sensitiveKey(k); ok && v != nil {
+			atomic.AddUint64(r.hits[key], 1)
+			result[k] = r.mask(fmt.Sprint(v))
+			continue
+		}
+		result[k] = r.redactValue(v)
+	}
+	return result
+}
+
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.redactString(val)
+	case map[string]interface{}:
+		return r.redactMap(val)
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = r.redactValue(item)
+		}
+		return result
+	case []string:
+		result := make([]string, len(val))
+		for i, item := range val {
+			result[i] = r.redactString(item)
+		}
+		return result
+	case map[string]string:
+		result := make(map[string]string, len(val))
+		for k, item := range val {
+			if key, ok := r.sensitiveKey(k); ok {
+				atomic.AddUint64(r.hits[key], 1)
+				result[k] = r.mask(item)
+				continue
+			}
+			result[k] = r.redactString(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) redactString(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.Regexp.ReplaceAllStringFunc(s, func(match string) string {
+			if pattern.Validate != nil && !pattern.Validate(match) {
+				return match
+			}
+			atomic.AddUint64(r.hits[pattern.Name], 1)
+			return r.mask(match)
+		})
+	}
+	return s
+}
+
+// sensitiveKey reports the configured key matching k. A key matches when its
+// segments appear in order in k, so "token" matches "refresh_token" and
+// "accessToken" but not "tokens_count".
+func (r *Redactor) sensitiveKey(k string) (string, bool) {
+	segments := keySegments(k)
+	for _, key := range r.keys {
+		if containsSegments(segments, key.segments) {
+			return key.name, true
+		}
+	}
+	return "", false
+}
+
+// keySegments splits a key on punctuation and camelCase boundaries and lowercases each part
+func keySegments(k string) []string {
+	var segments []string
+	var current []rune
+	runes := []rune(k)
+	for i, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			if len(current) > 0 {
+				segments = append(segments, string(current))
+				current = current[:0]
+			}
+			continue
+		}
+		if unicode.IsUpper(c) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				segments = append(segments, string(current))
+				current = current[:0]
+			}
+		}
+		current = append(current, unicode.ToLower(c))
+	}
+	if len(current) > 0 {
+		segments = append(segments, string(current))
+	}
+	return segments
+}
+
+// containsSegments reports whether sub appears as a contiguous run in segments
+func containsSegments(segments, sub []string) bool {
+	if len(sub) == 0 {
+		return false
+	}
+	for i := 0; i+len(sub) <= len(segments); i++ {
+		match := true
+		for j, s := range sub {
+			if segments[i+j] != s {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) mask(s string) string {
+	if r.config.MaskFunc != nil {
+		return r.config.MaskFunc(s)
+	}
+	switch r.config.MaskStyle {
+	case MaskPartial:
+		runes := []rune(s)
+		if len(runes) <= partialVisibleChars {
+			return strings.Repeat("*", len(runes))
+		}
+		hidden := len(runes) - partialVisibleChars
+		return strings.Repeat("*", hidden) + string(runes[hidden:])
+	case MaskHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])[:hashPrefixLength]
+	default:
+		return r.config.MaskPattern
+	}
+}
+
+// digits returns only the digits of s
+func digits(s string) []int {
+	result := make([]int, 0, len(s))
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			result = append(result, int(c-'0'))
+		}
+	}
+	return result
+}
+
+// validLuhn reports whether s is a 13-19 digit number passing the Luhn check
+func validLuhn(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		n := d[i]
+		if (len(d)-1-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// validThaiID reports whether s is a 13 digit Thai national ID with a valid check digit
+func validThaiID(s string) bool {
+	d := digits(s)
+	if len(d) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		sum += d[i] * (13 - i)
+	}
+	return (11-sum%11)%10 == d[12]
+}
@@ -0,0 +1,353 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const (
+	defaultMaxAttempts      = 3
+	defaultInitialBackoff   = 100 * time.Millisecond
+	defaultMaxBackoff       = 10 * time.Second
+	defaultBackoffFactor    = 2.0
+	defaultJitter           = 0.2
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by ResilientOutput while its circuit is open
+var ErrCircuitOpen = errors.New("output circuit open")
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as permanent, e.g. a sink rejecting malformed entries.
+// Outputs return it so retries are skipped.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err or an error it wraps was marked permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// WriteError describes a write that failed after all attempts
+type WriteError struct {
+	Output   string // name of the output
+	Attempts int    // write attempts made
+	Err      error  // error from the last attempt
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("output %s: write failed after %d attempts: %v", e.Output, e.Attempts, e.Err)
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// PartialWriteError describes a write that failed after some of the entries
+// were written, e.g. by an output sending them in several requests.
+// Wrappers that retry, spool or divert a failed write use Remaining instead
+// of the whole batch so written entries are not sent twice.
+type PartialWriteError struct {
+	Remaining []*core.Entry // entries not written, a suffix of the batch
+	Err       error         // error that stopped the write
+}
+
+func (e *PartialWriteError) Error() string { return e.Err.Error() }
+
+func (e *PartialWriteError) Unwrap() error { return e.Err }
+
+// unwritten returns the entries of a failed write of entries that still
+// need writing
+func unwritten(entries []*core.Entry, err error) []*core.Entry {
+	var partial *PartialWriteError
+	if errors.As(err, &partial) {
+		return partial.Remaining
+	}
+	return entries
+}
+
+// CircuitState represents the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets writes through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects writes until OpenTimeout has passed
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe write through
+	CircuitHalfOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// RetryConfig represents retry and circuit breaker configuration
+type RetryConfig struct {
+	Name             string                      // used in errors and metric names, defaults to "output"
+	MaxAttempts      int                         // attempts per Write including the first, defaults to 3
+	InitialBackoff   time.Duration               // delay before the first retry, defaults to 100ms
+	MaxBackoff       time.Duration               // cap on the delay, defaults to 10s
+	Factor           float64                     // backoff growth per attempt, defaults to 2
+	Jitter           float64                     // random spread as a fraction of the delay, defaults to 0.2
+	Retryable        func(err error) bool        // defaults to errors not marked Permanent
+	FailureThreshold int                         // consecutive failed writes that open the circuit, defaults to 5
+	OpenTimeout      time.Duration               // time the circuit stays open before a probe, defaults to 30s
+	Metrics          *core.SelfMetrics           // receives retry counts and circuit state, optional
+	OnStateChange    func(from, to CircuitState) // called on circuit transitions, must not call back into the output
+}
+
+// ResilientOutput wraps an output with retries using jittered exponential
+// backoff and a circuit breaker. Failed writes return a *WriteError; while
+// the circuit is open writes fail fast with ErrCircuitOpen.
+//
+// Self-metrics are reported as output.<name>.retries, .failures,
+// .permanent_failures, .circuit_opened and the .circuit_state gauge.
+type ResilientOutput struct {
+	output Output
+	config RetryConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewResilientOutput creates a resilient output
+func NewResilientOutput(output Output, config RetryConfig) *ResilientOutput {
+	if config.Name == "" {
+		config.Name = "output"
+	}
+	config.setBackoffDefaults()
+	if config.Retryable == nil {
+		config.Retryable = func(err error) bool { return !IsPermanent(err) }
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultOpenTimeout
+	}
+	o := &ResilientOutput{
+		output: output,
+		config: config,
+		now:    time.Now,
+		done:   make(chan struct{}),
+	}
+	config.Metrics.Set(o.metric("circuit_state"), int64(CircuitClosed))
+	return o
+}
+
+// Write writes entries, retrying retryable failures with backoff
+func (o *ResilientOutput) Write(entries []*core.Entry) error {
+	return o.WriteWithMetadata(entries, nil)
+}
+
+// WriteWithMetadata writes entries with metadata like Write. The metadata is
+// passed to the wrapped output, which drops it unless it is a MetadataWriter.
+// After a *PartialWriteError only the remaining entries are retried, and a
+// failed write returns one when some entries were written.
+func (o *ResilientOutput) WriteWithMetadata(entries []*core.Entry, metadata map[string]interface{}) error {
+	if !o.allow() {
+		return ErrCircuitOpen
+	}
+
+	var err error
+	remaining := entries
+	attempts := 0
+	for attempts < o.config.MaxAttempts {
+		attempts++
+		if err = writeWithMetadata(o.output, remaining, metadata); err == nil {
+			o.record(true)
+			return nil
+		}
+		remaining = unwritten(remaining, err)
+		if !o.config.Retryable(err) {
+			// the sink is up but rejected the entries, this says nothing about its health
+			o.config.Metrics.Add(o.metric("permanent_failures"), 1)
+			o.release()
+			return o.failed(entries, remaining, attempts, Permanent(err))
+		}
+		if attempts == o.config.MaxAttempts || !o.wait(o.config.backoff(attempts)) {
+			break
+		}
+		o.config.Metrics.Add(o.metric("retries"), 1)
+	}
+
+	o.config.Metrics.Add(o.metric("failures"), 1)
+	o.record(false)
+	return o.failed(entries, remaining, attempts, err)
+}
+
+// failed returns the *WriteError for a write of entries, wrapped in a
+// *PartialWriteError if only remaining still need writing
+func (o *ResilientOutput) failed(entries, remaining []*core.Entry, attempts int, err error) error {
+	err = &WriteError{Output: o.config.Name, Attempts: attempts, Err: err}
+	if len(remaining) < len(entries) {
+		return &PartialWriteError{Remaining: remaining, Err: err}
+	}
+	return err
+}
+
+// Flush flushes the wrapped output
+func (o *ResilientOutput) Flush() error {
+	return o.output.Flush()
+}
+
+// Close interrupts pending backoffs and closes the wrapped output
+func (o *ResilientOutput) Close() error {
+	o.once.Do(func() {
+		close(o.done)
+	})
+	return o.output.Close()
+}
+
+// State returns the current circuit state
+func (o *ResilientOutput) State() CircuitState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.state == CircuitOpen && o.now().Sub(o.openedAt) >= o.config.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return o.state
+}
+
+// allow reports whether a write may proceed, moving an expired open
+// circuit to half-open for a single probe
+func (o *ResilientOutput) allow() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch o.state {
+	case CircuitOpen:
+		if o.now().Sub(o.openedAt) < o.config.OpenTimeout {
+			return false
+		}
+		o.setStateLocked(CircuitHalfOpen)
+		o.probing = true
+		return true
+	case CircuitHalfOpen:
+		if o.probing {
+			return false
+		}
+		o.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// release ends a probe without a verdict on the output's health
+func (o *ResilientOutput) release() {
+	o.mu.Lock()
+	o.probing = false
+	o.mu.Unlock()
+}
+
+// record updates the circuit with the outcome of a write
+func (o *ResilientOutput) record(success bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.probing = false
+	if success {
+		o.failures = 0
+		o.setStateLocked(CircuitClosed)
+		return
+	}
+	o.failures++
+	if o.state == CircuitHalfOpen || o.failures >= o.config.FailureThreshold {
+		o.openedAt = o.now()
+		if o.state != CircuitOpen {
+			o.config.Metrics.Add(o.metric("circuit_opened"), 1)
+		}
+		o.setStateLocked(CircuitOpen)
+	}
+}
+
+func (o *ResilientOutput) setStateLocked(state CircuitState) {
+	if o.state == state {
+		return
+	}
+	from := o.state
+	o.state = state
+	o.config.Metrics.Set(o.metric("circuit_state"), int64(state))
+	if o.config.OnStateChange != nil {
+		o.config.OnStateChange(from, state)
+	}
+}
+
+// setBackoffDefaults fills in the attempt and backoff settings
+func (c *RetryConfig) setBackoffDefaults() {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.Factor < 1 {
+		c.Factor = defaultBackoffFactor
+	}
+	if c.Jitter <= 0 || c.Jitter > 1 {
+		c.Jitter = defaultJitter
+	}
+}
+
+// backoff returns the jittered delay before retry number attempt
+func (c *RetryConfig) backoff(attempt int) time.Duration {
+	delay := float64(c.InitialBackoff)
+	for i := 1; i < attempt && delay < float64(c.MaxBackoff); i++ {
+		delay *= c.Factor
+	}
+	delay = min(delay, float64(c.MaxBackoff))
+	delay *= 1 + c.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// wait sleeps for d, it reports false if the output was closed meanwhile
+func (o *ResilientOutput) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-o.done:
+		return false
+	}
+}
+
+func (o *ResilientOutput) metric(name string) string {
+	return "output." + o.config.Name + "." + name
+}
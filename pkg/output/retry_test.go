@@ -0,0 +1,195 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyOutput fails the first failures writes with err
+type flakyOutput struct {
+	*TestOutput
+	failures atomic.Int32
+	calls    atomic.Int32
+	err      error
+}
+
+func (o *flakyOutput) Write(entries []*core.Entry) error {
+	o.calls.Add(1)
+	if o.failures.Add(-1) >= 0 {
+		return o.err
+	}
+	return o.TestOutput.Write(entries)
+}
+
+func newFlakyOutput(failures int, err error) *flakyOutput {
+	o := &flakyOutput{TestOutput: NewTestOutput(), err: err}
+	o.failures.Store(int32(failures))
+	return o
+}
+
+// partialOutput writes only the first entry of its first write
+type partialOutput struct {
+	*TestOutput
+	calls atomic.Int32
+}
+
+func (o *partialOutput) Write(entries []*core.Entry) error {
+	if o.calls.Add(1) > 1 || len(entries) < 2 {
+		return o.TestOutput.Write(entries)
+	}
+	o.TestOutput.Write(entries[:1])
+	return &PartialWriteError{Remaining: entries[1:], Err: errors.New("timeout")}
+}
+
+func TestResilientOutput(t *testing.T) {
+	fast := RetryConfig{Name: "sink", InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("Retries until success", func(t *testing.T) {
+		target := newFlakyOutput(2, errors.New("timeout"))
+		config := fast
+		config.Metrics = core.NewSelfMetrics()
+		output := NewResilientOutput(target, config)
+
+		assert.NoError(t, output.Write(newQueueEntries(1)))
+		assert.Equal(t, int32(3), target.calls.Load())
+		assert.True(t, target.HasEntries())
+		assert.Equal(t, int64(2), config.Metrics.Get("output.sink.retries"))
+		assert.Zero(t, config.Metrics.Get("output.sink.failures"))
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		target := newFlakyOutput(10, errors.New("timeout"))
+		output := NewResilientOutput(target, fast)
+
+		err := output.Write(newQueueEntries(1))
+		var writeErr *WriteError
+		assert.ErrorAs(t, err, &writeErr)
+		assert.Equal(t, "sink", writeErr.Output)
+		assert.Equal(t, 3, writeErr.Attempts)
+		assert.EqualError(t, writeErr.Err, "timeout")
+		assert.False(t, IsPermanent(err))
+	})
+
+	t.Run("Permanent errors are not retried", func(t *testing.T) {
+		target := newFlakyOutput(10, Permanent(errors.New("malformed entry")))
+		config := fast
+		config.Metrics = core.NewSelfMetrics()
+		config.FailureThreshold = 1
+		output := NewResilientOutput(target, config)
+
+		err := output.Write(newQueueEntries(1))
+		assert.True(t, IsPermanent(err))
+		assert.Equal(t, int32(1), target.calls.Load())
+		assert.Equal(t, CircuitClosed, output.State(), "Permanent errors should not open the circuit")
+		assert.Equal(t, int64(1), config.Metrics.Get("output.sink.permanent_failures"))
+	})
+
+	t.Run("Retries remaining entries", func(t *testing.T) {
+		target := &partialOutput{TestOutput: NewTestOutput()}
+		output := NewResilientOutput(target, fast)
+
+		entries := newSpoolEntries(0, 3)
+		assert.NoError(t, output.Write(entries))
+		assert.Equal(t, traceIDs(entries), traceIDs(target.Entries()), "Written entries should not be retried")
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		target := &limitedOutput{TestOutput: NewTestOutput(), remaining: 1}
+		output := NewResilientOutput(target, fast)
+
+		entries := newSpoolEntries(0, 3)
+		err := output.Write(entries)
+		var partial *PartialWriteError
+		assert.ErrorAs(t, err, &partial)
+		assert.Equal(t, traceIDs(entries[1:]), traceIDs(partial.Remaining))
+		var writeErr *WriteError
+		assert.ErrorAs(t, err, &writeErr)
+		assert.Equal(t, 3, writeErr.Attempts)
+	})
+
+	t.Run("Circuit breaker", func(t *testing.T) {
+		target := newFlakyOutput(4, errors.New("connection refused"))
+		config := fast
+		config.MaxAttempts = 1
+		config.FailureThreshold = 2
+		config.OpenTimeout = time.Minute
+		config.Metrics = core.NewSelfMetrics()
+		var transitions []string
+		config.OnStateChange = func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		}
+		output := NewResilientOutput(target, config)
+		now := time.Now()
+		output.now = func() time.Time { return now }
+
+		output.Write(newQueueEntries(1))
+		assert.Equal(t, CircuitClosed, output.State())
+		output.Write(newQueueEntries(1))
+		assert.Equal(t, CircuitOpen, output.State())
+		assert.ErrorIs(t, output.Write(newQueueEntries(1)), ErrCircuitOpen)
+		assert.Equal(t, int32(2), target.calls.Load(), "Open circuit should not call the output")
+		assert.Equal(t, int64(CircuitOpen), config.Metrics.Get("output.sink.circuit_state"))
+
+		// failed probe reopens the circuit
+		now = now.Add(time.Minute)
+		assert.Equal(t, CircuitHalfOpen, output.State())
+		assert.Error(t, output.Write(newQueueEntries(1)))
+		assert.Equal(t, CircuitOpen, output.State())
+
+		// successful probe closes it
+		target.failures.Store(0)
+		now = now.Add(time.Minute)
+		assert.NoError(t, output.Write(newQueueEntries(1)))
+		assert.Equal(t, CircuitClosed, output.State())
+
+		assert.Equal(t, []string{"closed>open", "open>half_open", "half_open>open", "open>half_open", "half_open>closed"}, transitions)
+		assert.Equal(t, int64(2), config.Metrics.Get("output.sink.circuit_opened"))
+		assert.Equal(t, int64(CircuitClosed), config.Metrics.Get("output.sink.circuit_state"))
+	})
+
+	t.Run("Forwards metadata", func(t *testing.T) {
+		var buf bytes.Buffer
+		output := NewResilientOutput(NewWriterOutput(WriterConfig{Writer: &buf}), fast)
+
+		assert.NoError(t, output.WriteWithMetadata(newQueueEntries(1), map[string]interface{}{"region": "eu"}))
+		assert.Contains(t, buf.String(), `"region":"eu"`, "Metadata should reach the wrapped output")
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		output := NewResilientOutput(NewTestOutput(), RetryConfig{
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     time.Second,
+			Jitter:         0.1,
+		})
+		for attempt, expected := range map[int]time.Duration{
+			1: 100 * time.Millisecond,
+			2: 200 * time.Millisecond,
+			3: 400 * time.Millisecond,
+			6: time.Second,
+		} {
+			delay := output.config.backoff(attempt)
+			assert.InDelta(t, float64(expected), float64(delay), float64(expected)/10+1, "attempt %d", attempt)
+		}
+	})
+
+	t.Run("Close interrupts backoff", func(t *testing.T) {
+		target := newFlakyOutput(10, errors.New("timeout"))
+		output := NewResilientOutput(target, RetryConfig{InitialBackoff: time.Hour})
+		done := make(chan error)
+		go func() { done <- output.Write(newQueueEntries(1)) }()
+		assert.Eventually(t, func() bool { return target.calls.Load() == 1 }, time.Second, time.Millisecond)
+		output.Close()
+		select {
+		case err := <-done:
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Write should return after Close")
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+// eventLevels orders event levels for RouteRule.Level
+var eventLevels = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+// RouteRule selects the outputs receiving matching entries.
+// Empty conditions match every entry; all set conditions must match.
+type RouteRule struct {
+	Outputs     []string      // names of the outputs receiving matching entries
+	State       string        // entry state, e.g. "error"
+	Level       string        // minimum level of the entry's most severe event
+	PathPrefix  string        // request path prefix
+	Method      string        // request method
+	MinDuration time.Duration // minimum request duration
+	SpanKey     string        // key present in the input or output of any span
+	Sampler     core.Sampler  // consulted last, e.g. core.NewRatioSampler(0.1)
+	Stop        bool          // do not evaluate later rules for matching entries
+}
+
+// RouterConfig represents router output configuration
+type RouterConfig struct {
+	Outputs  map[string]Output // named child outputs
+	Rules    []RouteRule       // evaluated in order, an entry goes to the outputs of every matching rule
+	Defaults []string          // outputs for entries matching no rule
+}
+
+// RouterOutput fans entries out to named child outputs by rule, e.g. errors
+// to an alerting webhook, everything to a file and a sample to a remote store
+type RouterOutput struct {
+	config RouterConfig
+	names  []string // sorted output names
+}
+
+// NewRouterOutput creates a router output, it fails if a rule names an unknown output
+func NewRouterOutput(config RouterConfig) (*RouterOutput, error) {
+	for i, rule := range config.Rules {
+		for _, name := range rule.Outputs {
+			if _, ok := config.Outputs[name]; !ok {
+				return nil, fmt.Errorf("router: rule %d: unknown output %q", i, name)
+			}
+		}
+		if _, ok := eventLevels[rule.Level]; rule.Level != "" && !ok {
+			return nil, fmt.Errorf("router: rule %d: unknown level %q", i, rule.Level)
+		}
+	}
+	for _, name := range config.Defaults {
+		if _, ok := config.Outputs[name]; !ok {
+			return nil, fmt.Errorf("router: unknown default output %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(config.Outputs))
+	for name := range config.Outputs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return &RouterOutput{config: config, names: names}, nil
+}
+
+// Write routes entries and writes each output's share in one call
+func (o *RouterOutput) Write(entries []*core.Entry) error {
+	batches := make(map[string][]*core.Entry)
+	for _, entry := range entries {
+		for _, name := range o.Route(entry) {
+			batches[name] = append(batches[name], entry)
+		}
+	}
+
+	var errs []error
+	for _, name := range o.names {
+		if batch := batches[name]; len(batch) > 0 {
+			if err := o.config.Outputs[name].Write(batch); err != nil {
+				errs = append(errs, fmt.Errorf("output %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Route returns the names of the outputs receiving entry
+func (o *RouterOutput) Route(entry *core.Entry) []string {
+	var names []string
+	matched := false
+	for i := range o.config.Rules {
+		rule := &o.config.Rules[i]
+		if !rule.Match(entry) {
+			continue
+		}
+		matched = true
+		for _, name := range rule.Outputs {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		if rule.Stop {
+			break
+		}
+	}
+	if !matched {
+		return o.config.Defaults
+	}
+	return names
+}
+
+// Flush flushes all outputs
+func (o *RouterOutput) Flush() error {
+	var errs []error
+	for _, name := range o.names {
+		if err := o.config.Outputs[name].Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("output %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all outputs
+func (o *RouterOutput) Close() error {
+	var errs []error
+	for _, name := range o.names {
+		if err := o.config.Outputs[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("output %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Match reports whether entry satisfies all conditions of the rule
+func (r *RouteRule) Match(entry *core.Entry) bool {
+	state := entry.State
+	if entry.Error != nil {
+		state = "error"
+	}
+	if r.State != "" && r.State != state {
+		return false
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, entry.Method) {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(entry.OriginalPath, r.PathPrefix) {
+		return false
+	}
+	if r.MinDuration > 0 && time.Duration(entry.Duration*float64(time.Second)) < r.MinDuration {
+		return false
+	}
+	if r.Level != "" && entryLevel(entry) < eventLevels[r.Level] {
+		return false
+	}
+	if r.SpanKey != "" && !hasSpanKey(entry, r.SpanKey) {
+		return false
+	}
+	if r.Sampler != nil {
+		return r.Sampler.ShouldSample(core.SamplingParameters{
+			TraceID: entry.TraceID,
+			Method:  entry.Method,
+			Path:    entry.OriginalPath,
+		})
+	}
+	return true
+}
+
+// entryLevel returns the rank of the entry's most severe event, -1 without events.
+// An entry with an error ranks as error.
+func entryLevel(entry *core.Entry) int {
+	if entry.Error != nil {
+		return eventLevels["error"]
+	}
+	level := -1
+	for _, span := range entry.Spans {
+		if span.Event == nil {
+			continue
+		}
+		if rank, ok := eventLevels[span.Event.Level]; ok && rank > level {
+			level = rank
+		}
+	}
+	return level
+}
+
+func hasSpanKey(entry *core.Entry, key string) bool {
+	for _, span := range entry.Spans {
+		if _, ok := span.Input[key]; ok {
+			return true
+		}
+		if _, ok := span.Output[key]; ok {
+			return true
+		}
+	}
+	return false
+}
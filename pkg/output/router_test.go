@@ -0,0 +1,100 @@
+package output
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterOutput(t *testing.T) {
+	newEntry := func(state, method, path string, duration float64) *core.Entry {
+		return &core.Entry{TraceID: path, State: state, Method: method, OriginalPath: path, Duration: duration}
+	}
+
+	t.Run("Fan out by rule", func(t *testing.T) {
+		alerts, file, remote := NewTestOutput(), NewTestOutput(), NewTestOutput()
+		router, err := NewRouterOutput(RouterConfig{
+			Outputs: map[string]Output{"alerts": alerts, "file": file, "remote": remote},
+			Rules: []RouteRule{
+				{Outputs: []string{"alerts"}, State: "error"},
+				{Outputs: []string{"file"}},
+				{Outputs: []string{"remote"}, Sampler: core.NeverSample()},
+				{Outputs: []string{"remote"}, PathPrefix: "/api/payments", Method: "post"},
+			},
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, router.Write([]*core.Entry{
+			newEntry("success", "GET", "/api/users", 0.01),
+			newEntry("error", "GET", "/api/users", 0.01),
+			newEntry("success", "POST", "/api/payments/1", 0.01),
+		}))
+		assert.Equal(t, []string{"/api/users"}, traceIDs(alerts.Entries()))
+		assert.Len(t, file.Entries(), 3)
+		assert.Equal(t, []string{"/api/payments/1"}, traceIDs(remote.Entries()))
+	})
+
+	t.Run("Duration, stop and defaults", func(t *testing.T) {
+		slow, fast, fallback := NewTestOutput(), NewTestOutput(), NewTestOutput()
+		router, err := NewRouterOutput(RouterConfig{
+			Outputs: map[string]Output{"slow": slow, "fast": fast, "fallback": fallback},
+			Rules: []RouteRule{
+				{Outputs: []string{"slow"}, MinDuration: time.Second, Stop: true},
+				{Outputs: []string{"fast", "fast"}, Method: "GET"},
+			},
+			Defaults: []string{"fallback"},
+		})
+		assert.NoError(t, err)
+
+		router.Write([]*core.Entry{
+			newEntry("success", "GET", "/slow", 2),
+			newEntry("success", "GET", "/fast", 0.1),
+			newEntry("success", "PUT", "/other", 0.1),
+		})
+		assert.Equal(t, []string{"/slow"}, traceIDs(slow.Entries()))
+		assert.Equal(t, []string{"/fast"}, traceIDs(fast.Entries()), "Stop should skip later rules and outputs should not repeat")
+		assert.Equal(t, []string{"/other"}, traceIDs(fallback.Entries()), "Unmatched entries should go to the defaults")
+	})
+
+	t.Run("Span key", func(t *testing.T) {
+		audit := NewTestOutput()
+		router, err := NewRouterOutput(RouterConfig{
+			Outputs: map[string]Output{"audit": audit},
+			Rules:   []RouteRule{{Outputs: []string{"audit"}, SpanKey: "user_id"}},
+		})
+		assert.NoError(t, err)
+
+		entries := []*core.Entry{
+			newEntry("success", "GET", "/input", 0.01),
+			newEntry("success", "GET", "/output", 0.01),
+			newEntry("success", "GET", "/none", 0.01),
+		}
+		for _, entry := range entries {
+			entry.Spans = slices.Grow(entry.Spans, 1)[:1]
+		}
+		entries[0].Spans[0].Input = map[string]interface{}{"user_id": 1}
+		entries[1].Spans[0].Output = map[string]interface{}{"user_id": 1}
+		entries[2].Spans[0].Input = map[string]interface{}{"id": 1}
+		assert.NoError(t, router.Write(entries))
+		assert.Equal(t, []string{"/input", "/output"}, traceIDs(audit.Entries()), "Entries should match on span input and output keys")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := NewRouterOutput(RouterConfig{Rules: []RouteRule{{Outputs: []string{"missing"}}}})
+		assert.Error(t, err)
+		_, err = NewRouterOutput(RouterConfig{Outputs: map[string]Output{"a": NewTestOutput()}, Rules: []RouteRule{{Outputs: []string{"a"}, Level: "fatal"}}})
+		assert.Error(t, err)
+
+		ok := NewTestOutput()
+		router, _ := NewRouterOutput(RouterConfig{
+			Outputs: map[string]Output{"broken": &failingOutput{}, "ok": ok},
+			Rules:   []RouteRule{{Outputs: []string{"broken", "ok"}}},
+		})
+		err = router.Write(newQueueEntries(1))
+		assert.ErrorContains(t, err, "output broken")
+		assert.True(t, ok.HasEntries(), "A failing output should not block the others")
+	})
+}
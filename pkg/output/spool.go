@@ -0,0 +1,542 @@
+package output
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+const (
+	defaultSegmentSize   = 8 * megabyte
+	defaultRetryInterval = 5 * time.Second
+	defaultReplayBatch   = 100
+
+	segmentSuffix  = ".wal"
+	positionFile   = "position"
+	recordHeader   = 8 // length and crc32 of the payload
+	maxRecordBytes = 64 * megabyte
+)
+
+var errCorruptRecord = errors.New("spool: corrupt record")
+
+// SpoolConfig represents disk spool configuration
+type SpoolConfig struct {
+	Dir           string          // directory holding the segments, created if missing
+	SegmentSize   int64           // bytes per segment file, defaults to 8MB
+	MaxBytes      int64           // disk cap, the oldest segments are evicted beyond it; 0 disables
+	RetryInterval time.Duration   // delay between replay attempts while the output fails, defaults to 5s
+	BatchSize     int             // entries per replayed Write, defaults to 100
+	OnError       func(err error) // called with replay and disk errors
+}
+
+// SpoolStats represents disk spool statistics
+type SpoolStats struct {
+	PendingBytes int64  // spooled bytes not yet replayed
+	Segments     int    // segment files on disk
+	Spooled      uint64 // entries written to disk because the output failed or was behind
+	Replayed     uint64 // entries replayed to the output
+	Evicted      uint64 // segments removed by the disk cap before being replayed
+	Corrupted    uint64 // segments cut short by a corrupt or torn record
+}
+
+// SpoolOutput wraps an output with a segmented write-ahead log on disk.
+// While the wrapped output fails, entries are appended to the log and
+// replayed in order once it recovers. Unreplayed entries survive restarts.
+// Replayed entries are decoded from JSON, so map values come back with
+// JSON types (numbers as float64).
+type SpoolOutput struct {
+	output Output
+	config SpoolConfig
+
+	mu       sync.Mutex
+	segments []*segment // oldest first, the last one is active
+	active   *os.File
+	readID   uint64 // segment being replayed
+	readOff  int64  // offset of the next record in readID
+
+	replayMu sync.Mutex // serializes replay between the worker and Flush
+	notify   chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+
+	spooled   atomic.Uint64
+	replayed  atomic.Uint64
+	evicted   atomic.Uint64
+	corrupted atomic.Uint64
+
+	afterRead func() // test hook, runs between reading a batch and checking it
+}
+
+type segment struct {
+	id   uint64
+	size int64
+}
+
+// NewSpoolOutput opens or creates the spool in config.Dir and starts replaying
+// entries left by a previous run
+func NewSpoolOutput(output Output, config SpoolConfig) (*SpoolOutput, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("spool: dir is required")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSegmentSize
+	}
+	if config.MaxBytes > 0 && config.SegmentSize > config.MaxBytes/2 {
+		// only whole segments are evicted, keep room for at least two
+		config.SegmentSize = max(config.MaxBytes/2, 1)
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultRetryInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultReplayBatch
+	}
+
+	o := &SpoolOutput{
+		output: output,
+		config: config,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if err := o.open(); err != nil {
+		return nil, err
+	}
+	o.wg.Add(1)
+	go o.run()
+	o.wake()
+	return o, nil
+}
+
+// Write writes entries to the wrapped output. If the output fails or older
+// entries are still spooled, the entries it did not write are appended to the
+// log instead and Write only fails if they cannot be stored on disk.
+func (o *SpoolOutput) Write(entries []*core.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	o.mu.Lock()
+	pending := o.pendingLocked() > 0
+	o.mu.Unlock()
+
+	if !pending {
+		err := o.output.Write(entries)
+		if err == nil {
+			return nil
+		}
+		entries = unwritten(entries, err)
+	}
+	if err := o.append(entries); err != nil {
+		return err
+	}
+	o.wake()
+	return nil
+}
+
+// Flush replays spooled entries, syncs the log and flushes the wrapped output
+func (o *SpoolOutput) Flush() error {
+	replayErr := o.replay()
+
+	o.mu.Lock()
+	err := o.active.Sync()
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if replayErr != nil {
+		return replayErr
+	}
+	return o.output.Flush()
+}
+
+// Close stops replaying and closes the log and the wrapped output.
+// Entries not yet replayed stay on disk for the next run.
+func (o *SpoolOutput) Close() error {
+	o.once.Do(func() {
+		close(o.done)
+	})
+	o.wg.Wait()
+
+	o.mu.Lock()
+	err := o.active.Sync()
+	if cerr := o.active.Close(); err == nil {
+		err = cerr
+	}
+	o.mu.Unlock()
+
+	if cerr := o.output.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Stats returns spool statistics
+func (o *SpoolOutput) Stats() SpoolStats {
+	o.mu.Lock()
+	pending, segments := o.pendingLocked(), len(o.segments)
+	o.mu.Unlock()
+	return SpoolStats{
+		PendingBytes: pending,
+		Segments:     segments,
+		Spooled:      o.spooled.Load(),
+		Replayed:     o.replayed.Load(),
+		Evicted:      o.evicted.Load(),
+		Corrupted:    o.corrupted.Load(),
+	}
+}
+
+func (o *SpoolOutput) wake() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run replays on notification and retries periodically while entries are pending
+func (o *SpoolOutput) run() {
+	defer o.wg.Done()
+	ticker := time.NewTicker(o.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.notify:
+		case <-ticker.C:
+		case <-o.done:
+			return
+		}
+		if err := o.replay(); err != nil && o.config.OnError != nil {
+			o.config.OnError(err)
+		}
+	}
+}
+
+// replay writes spooled batches to the output until the log is drained
+// or the output fails
+func (o *SpoolOutput) replay() error {
+	o.replayMu.Lock()
+	defer o.replayMu.Unlock()
+
+	for {
+		o.mu.Lock()
+		if o.pendingLocked() == 0 {
+			o.mu.Unlock()
+			return nil
+		}
+		id, off, evicted := o.readID, o.readOff, o.evicted.Load()
+		seg := o.segmentLocked(id)
+		limit, active := seg.size, seg == o.segments[len(o.segments)-1]
+		if off >= limit && !active {
+			o.advanceLocked()
+			o.mu.Unlock()
+			continue
+		}
+		o.mu.Unlock()
+
+		batch, ends, err := o.readBatch(id, off, limit)
+		next := off
+		if len(ends) > 0 {
+			next = ends[len(ends)-1]
+		}
+		if errors.Is(err, errCorruptRecord) {
+			// skip the rest of the segment, everything before it was read
+			o.corrupted.Add(1)
+			next = limit
+			if o.config.OnError != nil {
+				o.config.OnError(fmt.Errorf("%w in segment %d at offset %d", err, id, off))
+			}
+		} else if err != nil {
+			return err
+		}
+		if o.afterRead != nil {
+			o.afterRead()
+		}
+
+		o.mu.Lock()
+		stale := o.evicted.Load() != evicted
+		o.mu.Unlock()
+		if stale {
+			// the disk cap evicted entries while the batch was read, it may
+			// hold some of them; read again from the new position
+			continue
+		}
+
+		if len(batch) > 0 {
+			if err := o.output.Write(batch); err != nil {
+				// keep the entries the output wrote from being replayed again
+				if written := len(batch) - len(unwritten(batch, err)); written > 0 {
+					o.replayed.Add(uint64(written))
+					err = errors.Join(err, o.commit(ends[written-1], evicted))
+				}
+				return err
+			}
+			o.replayed.Add(uint64(len(batch)))
+		}
+
+		if err := o.commit(next, evicted); err != nil {
+			return err
+		}
+		if next == off && len(batch) == 0 {
+			return nil
+		}
+	}
+}
+
+// commit moves the read position of the segment being replayed to off,
+// unless eviction moved it since evicted was loaded
+func (o *SpoolOutput) commit(off int64, evicted uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.evicted.Load() != evicted {
+		return nil
+	}
+	o.readOff = off
+	return o.savePositionLocked()
+}
+
+// readBatch decodes up to BatchSize records of segment id from off,
+// stopping at limit, and returns the offset after each record read
+func (o *SpoolOutput) readBatch(id uint64, off, limit int64) ([]*core.Entry, []int64, error) {
+	file, err := os.Open(o.segmentPath(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(io.NewSectionReader(file, off, limit-off))
+
+	var batch []*core.Entry
+	var ends []int64
+	var header [recordHeader]byte
+	for len(batch) < o.config.BatchSize && off < limit {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return batch, ends, errCorruptRecord
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+		if size > maxRecordBytes || off+recordHeader+int64(size) > limit {
+			return batch, ends, errCorruptRecord
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil || crc32.ChecksumIEEE(payload) != sum {
+			return batch, ends, errCorruptRecord
+		}
+		entry := &core.Entry{}
+		if err := json.Unmarshal(payload, entry); err != nil {
+			return batch, ends, errCorruptRecord
+		}
+		off += recordHeader + int64(size)
+		batch = append(batch, entry)
+		ends = append(ends, off)
+	}
+	return batch, ends, nil
+}
+
+// append writes entries to the active segment, rotating and evicting
+// segments as needed
+func (o *SpoolOutput) append(entries []*core.Entry) error {
+	var record []byte
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		record = record[:0]
+		record = binary.BigEndian.AppendUint32(record, uint32(len(payload)))
+		record = binary.BigEndian.AppendUint32(record, crc32.ChecksumIEEE(payload))
+		record = append(record, payload...)
+
+		active := o.segments[len(o.segments)-1]
+		if active.size > 0 && active.size+int64(len(record)) > o.config.SegmentSize {
+			if err := o.rotateLocked(); err != nil {
+				return err
+			}
+			active = o.segments[len(o.segments)-1]
+		}
+		n, err := o.active.Write(record)
+		active.size += int64(n)
+		if err != nil {
+			return err
+		}
+		o.spooled.Add(1)
+	}
+	return o.evictLocked()
+}
+
+// evictLocked removes the oldest segments while the spool is over MaxBytes
+func (o *SpoolOutput) evictLocked() error {
+	if o.config.MaxBytes <= 0 {
+		return nil
+	}
+	total := int64(0)
+	for _, seg := range o.segments {
+		total += seg.size
+	}
+	for total > o.config.MaxBytes && len(o.segments) > 1 {
+		oldest := o.segments[0]
+		total -= oldest.size
+		if oldest.id >= o.readID {
+			o.evicted.Add(1)
+		}
+		if oldest.id == o.readID {
+			o.advanceLocked()
+			continue
+		}
+		o.segments = o.segments[1:]
+		if err := os.Remove(o.segmentPath(oldest.id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceLocked deletes the segment being read and moves to the next one
+func (o *SpoolOutput) advanceLocked() {
+	if len(o.segments) < 2 {
+		return
+	}
+	for len(o.segments) > 1 && o.segments[0].id <= o.readID {
+		os.Remove(o.segmentPath(o.segments[0].id))
+		o.segments = o.segments[1:]
+	}
+	o.readID, o.readOff = o.segments[0].id, 0
+	if err := o.savePositionLocked(); err != nil && o.config.OnError != nil {
+		o.config.OnError(err)
+	}
+}
+
+// pendingLocked returns the number of spooled bytes not yet replayed
+func (o *SpoolOutput) pendingLocked() int64 {
+	pending := -o.readOff
+	for _, seg := range o.segments {
+		if seg.id >= o.readID {
+			pending += seg.size
+		}
+	}
+	return max(pending, 0)
+}
+
+func (o *SpoolOutput) segmentLocked(id uint64) *segment {
+	for _, seg := range o.segments {
+		if seg.id == id {
+			return seg
+		}
+	}
+	return o.segments[0]
+}
+
+// open loads existing segments and the read position, and starts a new
+// active segment so a torn record from a crash is never appended to
+func (o *SpoolOutput) open() error {
+	if err := os.MkdirAll(o.config.Dir, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(o.config.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			os.Remove(filepath.Join(o.config.Dir, name))
+			continue
+		}
+		o.segments = append(o.segments, &segment{id: id, size: info.Size()})
+	}
+	slices.SortFunc(o.segments, func(a, b *segment) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	o.loadPosition()
+	for len(o.segments) > 0 && o.segments[0].id < o.readID {
+		os.Remove(o.segmentPath(o.segments[0].id))
+		o.segments = o.segments[1:]
+	}
+	if len(o.segments) > 0 && o.segments[0].id != o.readID {
+		o.readID, o.readOff = o.segments[0].id, 0
+	}
+	if err := o.rotateLocked(); err != nil {
+		return err
+	}
+	if len(o.segments) == 1 {
+		o.readID, o.readOff = o.segments[0].id, 0
+	}
+	return o.savePositionLocked()
+}
+
+// rotateLocked closes the active segment and creates the next one
+func (o *SpoolOutput) rotateLocked() error {
+	id := uint64(1)
+	if n := len(o.segments); n > 0 {
+		id = o.segments[n-1].id + 1
+	}
+	file, err := os.OpenFile(o.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if o.active != nil {
+		o.active.Close()
+	}
+	o.active = file
+	o.segments = append(o.segments, &segment{id: id})
+	return nil
+}
+
+func (o *SpoolOutput) loadPosition() {
+	data, err := os.ReadFile(filepath.Join(o.config.Dir, positionFile))
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return
+	}
+	id, err1 := strconv.ParseUint(fields[0], 10, 64)
+	off, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 == nil && err2 == nil {
+		o.readID, o.readOff = id, off
+	}
+}
+
+// savePositionLocked atomically records the replay position
+func (o *SpoolOutput) savePositionLocked() error {
+	path := filepath.Join(o.config.Dir, positionFile)
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%d %d\n", o.readID, o.readOff)
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (o *SpoolOutput) segmentPath(id uint64) string {
+	return filepath.Join(o.config.Dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
@@ -0,0 +1,249 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// switchOutput fails while down is set
+type switchOutput struct {
+	*TestOutput
+	down atomic.Bool
+}
+
+func newSwitchOutput(down bool) *switchOutput {
+	o := &switchOutput{TestOutput: NewTestOutput(WithMaxEntries(100000))}
+	o.down.Store(down)
+	return o
+}
+
+func (o *switchOutput) Write(entries []*core.Entry) error {
+	if o.down.Load() {
+		return errors.New("sink unavailable")
+	}
+	return o.TestOutput.Write(entries)
+}
+
+func newSpoolEntries(from, n int) []*core.Entry {
+	entries := make([]*core.Entry, n)
+	for i := range entries {
+		entries[i] = &core.Entry{TraceID: fmt.Sprintf("trace-%d", from+i), State: "success"}
+	}
+	return entries
+}
+
+func traceIDs(entries []*core.Entry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.TraceID
+	}
+	return ids
+}
+
+func newTestSpool(t *testing.T, target Output, config SpoolConfig) *SpoolOutput {
+	if config.Dir == "" {
+		config.Dir = t.TempDir()
+	}
+	if config.RetryInterval == 0 {
+		config.RetryInterval = time.Hour
+	}
+	spool, err := NewSpoolOutput(target, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spool
+}
+
+func TestSpoolOutput(t *testing.T) {
+	t.Run("Healthy output bypasses the spool", func(t *testing.T) {
+		target := newSwitchOutput(false)
+		spool := newTestSpool(t, target, SpoolConfig{})
+		defer spool.Close()
+
+		assert.NoError(t, spool.Write(newSpoolEntries(0, 3)))
+		assert.Len(t, target.Entries(), 3)
+		assert.Zero(t, spool.Stats().Spooled)
+	})
+
+	t.Run("Replay after recovery", func(t *testing.T) {
+		target := newSwitchOutput(true)
+		spool := newTestSpool(t, target, SpoolConfig{BatchSize: 2})
+		defer spool.Close()
+
+		assert.NoError(t, spool.Write(newSpoolEntries(0, 3)), "Spooled writes should succeed")
+		target.down.Store(false)
+		assert.NoError(t, spool.Write(newSpoolEntries(3, 2)), "Writes should queue behind spooled entries")
+		assert.Empty(t, target.Entries())
+		assert.NotZero(t, spool.Stats().PendingBytes)
+
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, traceIDs(newSpoolEntries(0, 5)), traceIDs(target.Entries()), "Entries should be replayed in order")
+		stats := spool.Stats()
+		assert.Equal(t, uint64(5), stats.Spooled)
+		assert.Equal(t, uint64(5), stats.Replayed)
+		assert.Zero(t, stats.PendingBytes)
+	})
+
+	t.Run("Failed replay is retried", func(t *testing.T) {
+		target := newSwitchOutput(true)
+		spool := newTestSpool(t, target, SpoolConfig{RetryInterval: 10 * time.Millisecond})
+		defer spool.Close()
+
+		spool.Write(newSpoolEntries(0, 2))
+		assert.Error(t, spool.Flush())
+		target.down.Store(false)
+		assert.Eventually(t, func() bool { return len(target.Entries()) == 2 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Partial write", func(t *testing.T) {
+		target := &partialOutput{TestOutput: NewTestOutput()}
+		spool := newTestSpool(t, target, SpoolConfig{})
+		defer spool.Close()
+
+		assert.NoError(t, spool.Write(newSpoolEntries(0, 3)))
+		assert.Equal(t, uint64(2), spool.Stats().Spooled, "Only unwritten entries should be spooled")
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, traceIDs(newSpoolEntries(0, 3)), traceIDs(target.Entries()))
+	})
+
+	t.Run("Partial replay", func(t *testing.T) {
+		dir := t.TempDir()
+		spool := newTestSpool(t, newSwitchOutput(true), SpoolConfig{Dir: dir})
+		spool.Write(newSpoolEntries(0, 4))
+		assert.NoError(t, spool.Close())
+
+		partial := &limitedOutput{TestOutput: NewTestOutput(), remaining: 3}
+		spool = newTestSpool(t, partial, SpoolConfig{Dir: dir})
+		assert.Error(t, spool.Flush())
+		assert.Equal(t, uint64(3), spool.Stats().Replayed)
+		assert.NoError(t, spool.Close())
+
+		target := newSwitchOutput(false)
+		spool = newTestSpool(t, target, SpoolConfig{Dir: dir})
+		defer spool.Close()
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, []string{"trace-3"}, traceIDs(target.Entries()), "Written part of the batch should not be replayed")
+	})
+
+	t.Run("Survives restart", func(t *testing.T) {
+		dir := t.TempDir()
+		spool := newTestSpool(t, newSwitchOutput(true), SpoolConfig{Dir: dir, BatchSize: 1})
+		spool.Write(newSpoolEntries(0, 4))
+		assert.NoError(t, spool.Close())
+
+		// replay part of the log, then restart again
+		partial := &limitedOutput{TestOutput: NewTestOutput(), remaining: 1}
+		spool = newTestSpool(t, partial, SpoolConfig{Dir: dir, BatchSize: 1})
+		spool.Flush()
+		assert.NoError(t, spool.Close())
+		assert.Equal(t, []string{"trace-0"}, traceIDs(partial.Entries()))
+
+		target := newSwitchOutput(false)
+		spool = newTestSpool(t, target, SpoolConfig{Dir: dir})
+		defer spool.Close()
+		assert.Eventually(t, func() bool { return len(target.Entries()) == 3 }, time.Second, 5*time.Millisecond,
+			"Pending entries should be replayed on start")
+		assert.Equal(t, traceIDs(newSpoolEntries(1, 3)), traceIDs(target.Entries()))
+	})
+
+	t.Run("Disk cap evicts oldest", func(t *testing.T) {
+		dir := t.TempDir()
+		target := newSwitchOutput(true)
+		record := int64(recordHeader + len(mustJSON(t, newSpoolEntries(10, 1)[0])))
+		spool := newTestSpool(t, target, SpoolConfig{Dir: dir, SegmentSize: 2 * record, MaxBytes: 6 * record})
+		defer spool.Close()
+
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, spool.Write(newSpoolEntries(10+i, 1)))
+		}
+		stats := spool.Stats()
+		assert.NotZero(t, stats.Evicted)
+		assert.LessOrEqual(t, stats.PendingBytes, 6*record)
+		files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+		assert.LessOrEqual(t, len(files), 3)
+
+		target.down.Store(false)
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, traceIDs(newSpoolEntries(24, 6)), traceIDs(target.Entries()), "Newest entries should be kept")
+	})
+
+	t.Run("Eviction during replay", func(t *testing.T) {
+		target := newSwitchOutput(true)
+		record := int64(recordHeader + len(mustJSON(t, newSpoolEntries(10, 1)[0])))
+		spool := newTestSpool(t, target, SpoolConfig{SegmentSize: 2 * record, MaxBytes: 4 * record})
+		defer spool.Close()
+
+		spool.afterRead = func() {
+			spool.afterRead = nil
+			// evict the segment whose batch was just read
+			assert.NoError(t, spool.append(newSpoolEntries(12, 4)))
+		}
+		assert.NoError(t, spool.Write(newSpoolEntries(10, 2)))
+		target.down.Store(false)
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, traceIDs(newSpoolEntries(12, 4)), traceIDs(target.Entries()), "Evicted entries should not be replayed")
+		assert.Equal(t, uint64(1), spool.Stats().Evicted)
+	})
+
+	t.Run("Corrupt record", func(t *testing.T) {
+		dir := t.TempDir()
+		spool := newTestSpool(t, newSwitchOutput(true), SpoolConfig{Dir: dir})
+		spool.Write(newSpoolEntries(0, 2))
+		assert.NoError(t, spool.Close())
+
+		// simulate a torn write at the end of the segment
+		files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+		assert.Len(t, files, 1)
+		file, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0644)
+		assert.NoError(t, err)
+		file.Write([]byte{0, 0, 1, 0, 1, 2})
+		file.Close()
+
+		var reported atomic.Int32
+		target := newSwitchOutput(false)
+		spool = newTestSpool(t, target, SpoolConfig{Dir: dir, OnError: func(error) { reported.Add(1) }})
+		defer spool.Close()
+		assert.NoError(t, spool.Flush())
+		assert.Equal(t, []string{"trace-0", "trace-1"}, traceIDs(target.Entries()))
+		assert.Equal(t, uint64(1), spool.Stats().Corrupted)
+		assert.Zero(t, spool.Stats().PendingBytes)
+		assert.NotZero(t, reported.Load())
+	})
+}
+
+// limitedOutput accepts a number of entries and then fails
+type limitedOutput struct {
+	*TestOutput
+	remaining int
+}
+
+func (o *limitedOutput) Write(entries []*core.Entry) error {
+	n := min(len(entries), o.remaining)
+	o.remaining -= n
+	o.TestOutput.Write(entries[:n])
+	switch {
+	case n == len(entries):
+		return nil
+	case n > 0:
+		return &PartialWriteError{Remaining: entries[n:], Err: errors.New("sink unavailable")}
+	default:
+		return errors.New("sink unavailable")
+	}
+}
+
+func mustJSON(t *testing.T, entry *core.Entry) []byte {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
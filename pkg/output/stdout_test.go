@@ -3,7 +3,6 @@ package output
 import (
 	"bytes"
 	"encoding/json"
-	"io"
 	"os"
 	"testing"
 	"time"
@@ -13,84 +12,40 @@ import (
 )
 
 func TestStdoutOutput(t *testing.T) {
-	// Capture stdout
-	oldStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	// Restore stdout when done
-	defer func() {
-		os.Stdout = oldStdout
-	}()
-
-	config := StdoutConfig{
-		Colored: true,
-	}
-	output := NewStdoutOutput(config)
-
-	// Create test entries
-	now := time.Now()
-	entries := []*core.Entry{
-		{
-			Time:      now,
-			Level:     core.LevelInfo,
-			Message:   "Test message 1",
-			TraceID:   "trace-1",
-			SpanID:    "span-1",
-			RequestID: "req-1",
-			Data: map[string]interface{}{
-				"key1": "value1",
-			},
-		},
-		{
-			Time:      now,
-			Level:     core.LevelError,
-			Message:   "Test message 2",
-			TraceID:   "trace-2",
-			SpanID:    "span-2",
-			RequestID: "req-2",
-			Data: map[string]interface{}{
-				"key2": "value2",
-			},
-		},
-	}
-
-	// Write entries
-	err := output.Write(entries)
-	assert.NoError(t, err, "Write should not return error")
-
-	// Close pipe
-	w.Close()
-
-	// Read captured output
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-
-	// Split output into lines
-	lines := bytes.Split(buf.Bytes(), []byte("\n"))
-	assert.Equal(t, 3, len(lines), "Should have 2 lines plus empty line")
-
-	// Parse and verify each line
-	for i, line := range lines[:2] {
-		var logEntry map[string]interface{}
-		err := json.Unmarshal(line, &logEntry)
-		assert.NoError(t, err, "Should be valid JSON")
-
-		// Verify fields
-		assert.Equal(t, entries[i].Time.Format(time.RFC3339), logEntry["timestamp"])
-		assert.Equal(t, entries[i].Level.String(), logEntry["level"])
-		assert.Equal(t, entries[i].Message, logEntry["message"])
-
-		data := logEntry["data"].(map[string]interface{})
-		assert.Equal(t, entries[i].TraceID, data["trace_id"])
-		assert.Equal(t, entries[i].SpanID, data["span_id"])
-		assert.Equal(t, entries[i].RequestID, data["request_id"])
-		for k, v := range entries[i].Data {
-			assert.Equal(t, v, data[k])
+	t.Run("Writes to stdout", func(t *testing.T) {
+		output := NewStdoutOutput(StdoutConfig{Pretty: true})
+		assert.Equal(t, os.Stdout, output.config.Writer)
+		assert.True(t, output.config.Pretty, "Config should be passed to the writer output")
+		assert.NoError(t, output.Flush(), "Flush should not return error")
+		assert.NoError(t, output.Close(), "Close should not return error")
+	})
+
+	t.Run("JSON lines", func(t *testing.T) {
+		var buf bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &buf})
+		entries := []*core.Entry{newBenchEntry(), newBenchEntry()}
+		entries[1].TraceID, entries[1].RequestID, entries[1].State = "trace-2", "req-2", "error"
+		assert.NoError(t, output.Write(entries), "Write should not return error")
+
+		lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+		assert.Len(t, lines, 2, "Should write one line per entry")
+		for i, line := range lines {
+			var logEntry map[string]interface{}
+			assert.NoError(t, json.Unmarshal(line, &logEntry), "Should be valid JSON")
+			assert.Equal(t, entries[i].TraceID, logEntry["trace_id"])
+			assert.Equal(t, entries[i].RequestID, logEntry["request_id"])
+			assert.Equal(t, entries[i].State, logEntry["state"])
+			assert.Equal(t, entries[i].Method, logEntry["method"])
+			assert.Equal(t, entries[i].OriginalPath, logEntry["original_path"])
+			assert.Equal(t, entries[i].StartTime.Format(time.RFC3339), logEntry["start_time"])
 		}
-	}
-
-	// Test Flush and Close
-	assert.NoError(t, output.Flush(), "Flush should not return error")
-	assert.NoError(t, output.Close(), "Close should not return error")
+	})
+
+	t.Run("Colored without terminal", func(t *testing.T) {
+		var buf bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &buf, Colored: true})
+		assert.NoError(t, output.Write([]*core.Entry{newBenchEntry()}))
+		assert.Contains(t, buf.String(), "GET /api/users success")
+		assert.NotContains(t, buf.String(), "\x1b[", "Colour should be off when not writing to a terminal")
+	})
 }
@@ -26,26 +26,16 @@ func TestTestOutput(t *testing.T) {
 		// Create test entries
 		entries := []*core.Entry{
 			{
-				Time:      time.Now(),
-				Level:     core.LevelInfo,
-				Message:   "Test message 1",
+				StartTime: time.Now(),
+				State:     "success",
 				TraceID:   "trace-1",
-				SpanID:    "span-1",
 				RequestID: "req-1",
-				Data: map[string]interface{}{
-					"key1": "value1",
-				},
 			},
 			{
-				Time:      time.Now(),
-				Level:     core.LevelError,
-				Message:   "Test message 2",
+				StartTime: time.Now(),
+				State:     "error",
 				TraceID:   "trace-2",
-				SpanID:    "span-2",
 				RequestID: "req-2",
-				Data: map[string]interface{}{
-					"key2": "value2",
-				},
 			},
 		}
 
@@ -81,9 +71,9 @@ func TestTestOutput(t *testing.T) {
 		entries := make([]*core.Entry, 5)
 		for i := 0; i < 5; i++ {
 			entries[i] = &core.Entry{
-				Time:    time.Now(),
-				Level:   core.LevelInfo,
-				Message: fmt.Sprintf("Test message %d", i+1),
+				StartTime: time.Now(),
+				State:     "success",
+				TraceID:   fmt.Sprintf("trace-%d", i+1),
 			}
 		}
 
@@ -105,9 +95,9 @@ func TestTestOutput(t *testing.T) {
 	t.Run("Concurrent access", func(t *testing.T) {
 		output := NewTestOutput()
 		entries := []*core.Entry{{
-			Time:    time.Now(),
-			Level:   core.LevelInfo,
-			Message: "Test message",
+			StartTime: time.Now(),
+			State:     "success",
+			TraceID:   "trace-1",
 		}}
 
 		// Run concurrent operations
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+)
+
+// WriterConfig represents writer output configuration
+type WriterConfig struct {
+	Writer      io.Writer // destination, defaults to os.Stdout
+	ErrorWriter io.Writer // receives entries in the error state, defaults to Writer
+	Pretty      bool      // use indented JSON when Formatter is not set
+	Colored     bool      // use the console formatter when Formatter is not set, colour only on a terminal
+	Formatter   Formatter // formats each entry, defaults to JSON
+	Redactor    *Redactor // masks sensitive data before encoding, optional
+	Limiter     *Limiter  // enforces size limits after redaction, optional
+}
+
+// WriterOutput writes formatted entries to an io.Writer.
+// It is safe for concurrent use; each entry is written with a single
+// Write call so lines from concurrent writers never interleave.
+type WriterOutput struct {
+	config    WriterConfig
+	formatter Formatter
+	mu        sync.Mutex
+}
+
+// NewWriterOutput creates a new writer output handler
+func NewWriterOutput(config WriterConfig) *WriterOutput {
+	if config.Writer == nil {
+		config.Writer = os.Stdout
+	}
+	if config.ErrorWriter == nil {
+		config.ErrorWriter = config.Writer
+	}
+	formatter := config.Formatter
+	if formatter == nil && config.Colored {
+		formatter = NewConsoleFormatter(isTerminal(config.Writer))
+	}
+	return &WriterOutput{
+		config:    config,
+		formatter: defaultFormatter(formatter, config.Pretty),
+	}
+}
+
+// Write writes entries to the writer
+func (o *WriterOutput) Write(entries []*core.Entry) error {
+	return o.WriteWithMetadata(entries, nil)
+}
+
+// WriteWithMetadata writes entries with metadata added to each of them
+func (o *WriterOutput) WriteWithMetadata(entries []*core.Entry, metadata map[string]interface{}) error {
+	for _, entry := range entries {
+		if err := o.write(entry, metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write encodes a single entry using pooled objects
+func (o *WriterOutput) write(entry *core.Entry, metadata map[string]interface{}) error {
+	logEntry := acquireLogEntry(entry)
+	defer releaseLogEntry(logEntry)
+	for k, v := range metadata {
+		logEntry.Metadata[k] = v
+	}
+
+	// Mask sensitive data
+	if o.config.Redactor != nil {
+		o.config.Redactor.Redact(logEntry)
+	}
+
+	// Enforce size limits
+	if o.config.Limiter != nil {
+		o.config.Limiter.Apply(logEntry)
+	}
+
+	// Format and write
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	var err error
+	if buf.bytes, err = o.formatter.Format(buf.bytes, logEntry); err != nil {
+		return err
+	}
+
+	w := o.config.Writer
+	if logEntry.State == "error" {
+		w = o.config.ErrorWriter
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err = w.Write(buf.bytes)
+	return err
+}
+
+// Flush flushes writers that buffer, such as *bufio.Writer
+func (o *WriterOutput) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := flushWriter(o.config.Writer); err != nil {
+		return err
+	}
+	if o.config.ErrorWriter != o.config.Writer {
+		return flushWriter(o.config.ErrorWriter)
+	}
+	return nil
+}
+
+// Close flushes the writers, it does not close them
+func (o *WriterOutput) Close() error {
+	return o.Flush()
+}
+
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nat-prohmpiriya/goobserv/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterOutput(t *testing.T) {
+	t.Run("Split streams", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &out, ErrorWriter: &errOut})
+
+		ok := newBenchEntry()
+		failed := newBenchEntry()
+		failed.State = "error"
+		assert.NoError(t, output.Write([]*core.Entry{ok, failed}))
+
+		assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+		assert.Contains(t, out.String(), `"state":"success"`)
+		assert.Equal(t, 1, strings.Count(errOut.String(), "\n"))
+		assert.Contains(t, errOut.String(), `"state":"error"`, "Error entries should go to the error writer")
+	})
+
+	t.Run("Error writer defaults to writer", func(t *testing.T) {
+		var out bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &out})
+		failed := newBenchEntry()
+		failed.State = "error"
+		assert.NoError(t, output.Write([]*core.Entry{newBenchEntry(), failed}))
+		assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("Flush buffered writer", func(t *testing.T) {
+		var out bytes.Buffer
+		w := bufio.NewWriter(&out)
+		output := NewWriterOutput(WriterConfig{Writer: w})
+		assert.NoError(t, output.Write([]*core.Entry{newBenchEntry()}))
+		assert.Zero(t, out.Len(), "Entry should still be buffered")
+		assert.NoError(t, output.Close())
+		assert.NotZero(t, out.Len(), "Close should flush the writer")
+	})
+
+	t.Run("Tail sampling decision", func(t *testing.T) {
+		var out bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &out})
+		obs := core.NewObserver(&core.Config{
+			BufferSize:  4,
+			TailSampler: core.NewTailSampler(core.TailSamplerConfig{SlowThreshold: time.Second}),
+		})
+		slow := newBenchEntry()
+		slow.Duration = 2
+		assert.True(t, obs.Enqueue(slow))
+
+		assert.NoError(t, output.Write(obs.Dequeue(nil, 4)))
+		assert.Contains(t, out.String(), `"sampling":{"sampled":true,"reason":"slow"}`, "Sampling decision should be emitted")
+	})
+
+	t.Run("Concurrent writes", func(t *testing.T) {
+		var out bytes.Buffer
+		output := NewWriterOutput(WriterConfig{Writer: &out})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					output.Write([]*core.Entry{newBenchEntry()})
+				}
+			}()
+		}
+		wg.Wait()
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		assert.Len(t, lines, 400)
+		for _, line := range lines {
+			assert.True(t, strings.HasPrefix(line, `{"trace_id"`) && strings.HasSuffix(line, "}"), "Lines should not interleave")
+		}
+	})
+}